@@ -0,0 +1,98 @@
+package consistentHash
+
+import "sort"
+
+// AddBatch adds several servers with the configured vnode count in one step, readers see either none or all of them
+// If any server is already a member, or listed twice, ErrNodeExists is returned and nothing is added
+func (ch *ConsistentHash) AddBatch(addresses []string) error {
+	return ch.update(func() ([]string, []string, error) {
+		seen := make(map[string]bool, len(addresses))
+		for _, address := range addresses {
+			if _, found := ch.nodeCount[address]; found || seen[address] {
+				return nil, nil, ErrNodeExists
+			}
+			seen[address] = true
+		}
+		for _, address := range addresses {
+			ch.add(address, ch.vnodeCount)
+		}
+		return addresses, nil, nil
+	})
+}
+
+// RemoveBatch removes several servers in one step, readers see either all or none of them
+// If any server is not a member, or listed twice, ErrNodeNotFound is returned and nothing is removed
+func (ch *ConsistentHash) RemoveBatch(addresses []string) error {
+	return ch.update(func() ([]string, []string, error) {
+		seen := make(map[string]bool, len(addresses))
+		for _, address := range addresses {
+			if _, found := ch.nodeCount[address]; !found || seen[address] {
+				return nil, nil, ErrNodeNotFound
+			}
+			seen[address] = true
+		}
+		for _, address := range addresses {
+			ch.remove(address)
+		}
+		return nil, addresses, nil
+	})
+}
+
+// SetMembers replaces the membership with addresses in one step, each server getting the configured vnode count
+// Readers see either the old or the new membership, OnRemove and OnAdd hooks are called for the servers that left
+// and joined, servers in both keep their keys
+// If a server is listed twice ErrNodeExists is returned and the ring is left unchanged
+func (ch *ConsistentHash) SetMembers(addresses []string) error {
+	return ch.update(func() ([]string, []string, error) {
+		members := make(map[string]int, len(addresses))
+		for _, address := range addresses {
+			if _, found := members[address]; found {
+				return nil, nil, ErrNodeExists
+			}
+			members[address] = ch.vnodeCount
+		}
+		return ch.setMembers(members)
+	})
+}
+
+// SetMembersWithNodeCount is SetMembers with the vnode count of each server, like AddWithNodeCount
+// If any count is below 1 ErrInvalidVnodeCount is returned and the ring is left unchanged
+func (ch *ConsistentHash) SetMembersWithNodeCount(members map[string]int) error {
+	return ch.update(func() ([]string, []string, error) {
+		return ch.setMembers(members)
+	})
+}
+
+// setMembers rebuilds the ring with members and reports the servers that joined and left
+// The caller must hold the write lock
+func (ch *ConsistentHash) setMembers(members map[string]int) ([]string, []string, error) {
+	next := ch.blank()
+	addresses := make([]string, 0, len(members))
+	for address := range members {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	var addedServers, removedServers []string
+	for _, address := range addresses {
+		if err := next.add(address, members[address]); err != nil {
+			return nil, nil, err
+		}
+		if _, found := ch.nodeCount[address]; !found {
+			addedServers = append(addedServers, address)
+			continue
+		}
+		if zone, found := ch.zones[address]; found {
+			next.zones[address] = zone
+		}
+		if ch.draining[address] {
+			next.draining[address] = true
+		}
+	}
+	for _, address := range ch.sortedMembers() {
+		if _, found := members[address]; !found {
+			removedServers = append(removedServers, address)
+		}
+	}
+	ch.adopt(next)
+	return addedServers, removedServers, nil
+}
@@ -0,0 +1,136 @@
+package consistentHash
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddBatch(t *testing.T) {
+	ch := New()
+	var hooked []string
+	ch.OnAdd(func(server string) { hooked = append(hooked, server) })
+	assert.Nil(t, ch.AddBatch([]string{"server1", "server2"}))
+	assert.Equal(t, []string{"server1", "server2"}, ch.Members())
+	assert.Equal(t, []string{"server1", "server2"}, hooked)
+	assert.Equal(t, ErrNodeExists, ch.AddBatch([]string{"server3", "server2"}))
+	assert.Equal(t, ErrNodeExists, ch.AddBatch([]string{"server3", "server3"}))
+	assert.Equal(t, []string{"server1", "server2"}, ch.Members())
+
+	expected := New()
+	expected.Add("server1")
+	expected.Add("server2")
+	assert.Equal(t, expected.vnodes(), ch.vnodes())
+}
+
+func TestRemoveBatch(t *testing.T) {
+	ch := New()
+	addServers(ch, 4)
+	assert.Equal(t, ErrNodeNotFound, ch.RemoveBatch([]string{"server0", "server9"}))
+	assert.Equal(t, ErrNodeNotFound, ch.RemoveBatch([]string{"server0", "server0"}))
+	assert.Equal(t, 4, ch.Size())
+	assert.Nil(t, ch.RemoveBatch([]string{"server0", "server2"}))
+	assert.Equal(t, []string{"server1", "server3"}, ch.Members())
+	assert.Equal(t, 2*ch.vnodeCount, len(ch.positions))
+}
+
+// TestBatchAtomic verifies that a concurrent reader never observes a partially applied batch
+func TestBatchAtomic(t *testing.T) {
+	ch := New()
+	batch := make([]string, 10)
+	for i := range batch {
+		batch[i] = "server" + strconv.Itoa(i)
+	}
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			size := len(ch.Members())
+			if size != 0 && size != len(batch) {
+				t.Errorf("observed %d members", size)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, ch.AddBatch(batch))
+		assert.Nil(t, ch.RemoveBatch(batch))
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// TestSetMembers verifies that the membership is replaced, hooks see the diff and retained servers keep their keys
+func TestSetMembers(t *testing.T) {
+	ch := New()
+	addServers(ch, 4)
+	ch.AddWithZone("zoned", "zone1")
+	var addedServers, removedServers []string
+	ch.OnAdd(func(server string) { addedServers = append(addedServers, server) })
+	ch.OnRemove(func(server string) { removedServers = append(removedServers, server) })
+	before := ch.Clone()
+
+	assert.Nil(t, ch.SetMembers([]string{"server1", "zoned", "server3", "server9"}))
+	assert.Equal(t, []string{"server1", "server3", "server9", "zoned"}, ch.Members())
+	assert.Equal(t, []string{"server9"}, addedServers)
+	assert.Equal(t, []string{"server0", "server2"}, removedServers)
+	assert.Equal(t, "zone1", ch.Zone("zoned"))
+	for _, key := range keys {
+		old, _ := before.Get(key)
+		server, _ := ch.Get(key)
+		if old == "server1" || old == "server3" || old == "zoned" {
+			assert.True(t, server == old || server == "server9")
+		}
+	}
+
+	assert.Equal(t, ErrNodeExists, ch.SetMembers([]string{"a", "a"}))
+	assert.Equal(t, ErrInvalidVnodeCount, ch.SetMembersWithNodeCount(map[string]int{"a": 10, "b": 0}))
+	assert.Equal(t, 4, ch.Size())
+
+	assert.Nil(t, ch.SetMembersWithNodeCount(map[string]int{"server1": 10, "big": 400}))
+	assert.Equal(t, []string{"big", "server1"}, ch.Members())
+	assert.Equal(t, 10, ch.VnodeCount("server1"))
+	assert.Equal(t, 400, ch.VnodeCount("big"))
+	assert.Nil(t, ch.Validate())
+}
+
+// TestSetMembersAtomic verifies that a concurrent reader sees either the old or the new membership, never a mix
+func TestSetMembersAtomic(t *testing.T) {
+	ch := New()
+	old := []string{"a", "b", "c"}
+	next := []string{"c", "d"}
+	ch.SetMembers(old)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			members := ch.Members()
+			if !assert.ObjectsAreEqual(old, members) && !assert.ObjectsAreEqual(next, members) {
+				t.Errorf("observed members %v", members)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, ch.SetMembers(next))
+		assert.Nil(t, ch.SetMembers(old))
+	}
+	close(stop)
+	wg.Wait()
+}
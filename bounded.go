@@ -0,0 +1,48 @@
+package consistentHash
+
+// GetBounded finds the closest member for a key whose load is below capacity, implementing
+// consistent hashing with bounded loads (Mirrokni, Thorup and Zadimoghaddam)
+// The walk starts at the key's position and moves clockwise around the ring, skipping members whose
+// load[member] >= capacity, so a full member spills its keys onto the next members in ring order
+// The caller owns the load map and is responsible for incrementing the chosen member's load
+// ErrNoCapacity is returned if every member is full
+func (ch *ConsistentHash) GetBounded(key []byte, load map[string]int64, capacity int64) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return ch.bounded(key, func(address string) bool {
+		return load[address] >= capacity
+	})
+}
+
+// GetBoundedWeighted is GetBounded with the capacity of each member scaled by its vnode count, capacity being that
+// of a member with the average vnode count, so a server added with twice the vnodes takes twice the load before
+// its keys spill
+// On a ring where every member has the same vnode count it is GetBounded
+func (ch *ConsistentHash) GetBoundedWeighted(key []byte, load map[string]int64, capacity int64) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	mean := float64(len(ch.positions)) / float64(len(ch.nodeCount))
+	return ch.bounded(key, func(address string) bool {
+		return float64(load[address]) >= float64(capacity)*float64(len(ch.owned[address]))/mean
+	})
+}
+
+// bounded finds the closest member for a key that is not full
+// The caller must hold at least the read lock
+func (ch *ConsistentHash) bounded(key []byte, full func(address string) bool) (string, error) {
+	index, err := ch.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	found, ok := "", false
+	ch.successors(index, func(address string) bool {
+		if !full(address) {
+			found, ok = address, true
+		}
+		return !ok
+	})
+	if !ok {
+		return "", ErrNoCapacity
+	}
+	return found, nil
+}
@@ -0,0 +1,94 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetBoundedSpill verifies that a key spills to the next member once its owner is full
+func TestGetBoundedSpill(t *testing.T) {
+	ch := New()
+	addServers(ch, 3)
+	key := []byte("testKey")
+	load := make(map[string]int64)
+	servers, _ := ch.GetN(key, 3)
+	server, err := ch.GetBounded(key, load, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, servers[0], server)
+
+	load[servers[0]] = 1
+	server, err = ch.GetBounded(key, load, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, servers[1], server)
+
+	load[servers[1]] = 1
+	server, err = ch.GetBounded(key, load, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, servers[2], server)
+
+	load[servers[2]] = 1
+	_, err = ch.GetBounded(key, load, 1)
+	assert.Equal(t, ErrNoCapacity, err)
+
+	// a member may be named "", which must not be mistaken for no capacity
+	unnamed := New()
+	unnamed.Add("")
+	unnamed.Add("a")
+	server, err = unnamed.GetBounded(key, map[string]int64{"a": 1}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "", server)
+}
+
+// TestGetBoundedDistribution verifies that no member goes above the capacity bound
+func TestGetBoundedDistribution(t *testing.T) {
+	ch := New()
+	serverCount := 10
+	addServers(ch, serverCount)
+	_, err := New().GetBounded(keys[0], nil, 1)
+	assert.Equal(t, ErrEmptyRing, err)
+
+	// allow 25% more than the average load
+	capacity := int64(len(keys)*5/4/serverCount + 1)
+	load := make(map[string]int64)
+	for _, key := range keys {
+		server, err := ch.GetBounded(key, load, capacity)
+		assert.Nil(t, err)
+		load[server]++
+	}
+	var total int64
+	for server, count := range load {
+		assert.True(t, count <= capacity, "%s has load %d above capacity %d", server, count, capacity)
+		total += count
+	}
+	assert.Equal(t, int64(len(keys)), total)
+}
+
+// TestGetBoundedWeighted verifies that a member with twice the vnodes absorbs twice the keys before spilling while
+// the flat bound of GetBounded caps it like the others
+func TestGetBoundedWeighted(t *testing.T) {
+	ch := New()
+	_, err := ch.GetBoundedWeighted(keys[0], nil, 1)
+	assert.Equal(t, ErrEmptyRing, err)
+	ch.AddWithNodeCount("heavy", 400)
+	ch.AddWithNodeCount("light1", 200)
+	ch.AddWithNodeCount("light2", 200)
+
+	// 5% more than the average load, heavy gets 1.5 times that and each light member 0.75 times
+	capacity := int64(len(keys)*21/20/3 + 1)
+	load := make(map[string]int64)
+	flat := make(map[string]int64)
+	for _, key := range keys {
+		server, err := ch.GetBoundedWeighted(key, load, capacity)
+		assert.Nil(t, err)
+		load[server]++
+		server, err = ch.GetBounded(key, flat, capacity)
+		assert.Nil(t, err)
+		flat[server]++
+	}
+	heavyCapacity := float64(capacity) * 1.5
+	assert.True(t, float64(load["heavy"]) <= heavyCapacity, "heavy has load %d above %.0f", load["heavy"], heavyCapacity)
+	assert.True(t, float64(load["light1"]) <= float64(capacity)*0.75+1)
+	assert.InDelta(t, 2.0, float64(load["heavy"])/float64(load["light1"]), 0.3)
+	assert.True(t, flat["heavy"] <= capacity)
+}
@@ -0,0 +1,30 @@
+package consistentHash
+
+// CollisionStrategy decides what happens to a vnode whose position is already taken by another vnode
+// Collisions are rare with 64bit positions but become likely with a narrow hash such as HashCRC32 or very large rings
+type CollisionStrategy int
+
+const (
+	// CollisionTiebreak keeps both vnodes at the same position ordered by server name, the default
+	// Every server keeps all of its vnodes and the ring does not depend on the order servers were added in, but only
+	// the first vnode of a position receives keys, the others act as spares that take over when it is removed
+	CollisionTiebreak CollisionStrategy = iota
+	// CollisionProbe moves the new vnode to the next free position after the taken one
+	// No two vnodes share a position and every vnode receives keys, though a probed vnode only owns the positions up
+	// to the next vnode, so shares barely change, and which server gets moved depends on the order they were added in
+	CollisionProbe
+	// CollisionSkip drops the new vnode, unless all the vnodes of a server collide and it would have none
+	// The ring holds fewer vnodes and a server can end up with fewer than it was added with, see VnodeCount, which
+	// lowers its share of keys a little, and which server loses the vnode depends on the order they were added in
+	CollisionSkip
+)
+
+// WithCollisionStrategy sets how a vnode placed where another vnode already is gets resolved
+// With CollisionProbe and CollisionSkip the ring depends on the order servers are added in, so rings built from the
+// same members in different orders can differ, and freeing a position does not move or restore earlier vnodes
+// Rebalance, SetMembers and decoding add servers in name order
+func WithCollisionStrategy(strategy CollisionStrategy) Option {
+	return func(ch *ConsistentHash) {
+		ch.collision = strategy
+	}
+}
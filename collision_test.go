@@ -0,0 +1,74 @@
+package consistentHash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCollisionStrategy verifies how each strategy resolves replica 0 of every server landing on the same position
+// and that every server remains reachable
+func TestCollisionStrategy(t *testing.T) {
+	tests := []struct {
+		strategy CollisionStrategy
+		vnodes   int
+		at42     int
+	}{
+		{CollisionTiebreak, 50, 5},
+		{CollisionProbe, 50, 1},
+		{CollisionSkip, 46, 1},
+	}
+	for _, test := range tests {
+		ch := New(WithHashFunc(collidingHash), WithVnodeCount(10), WithCollisionStrategy(test.strategy))
+		for i := 0; i < 5; i++ {
+			ch.Add("server" + strconv.Itoa(i))
+		}
+		assert.Nil(t, ch.Validate())
+		assert.Equal(t, test.vnodes, ch.NumVnodes())
+		at42 := 0
+		for _, position := range ch.VnodePositions() {
+			if position == 42 {
+				at42++
+			}
+		}
+		assert.Equal(t, test.at42, at42)
+		reachable := make(map[string]bool)
+		for _, key := range keys {
+			server, _ := ch.Get(key)
+			reachable[server] = true
+		}
+		assert.Equal(t, 5, len(reachable))
+
+		assert.Nil(t, ch.Remove("server0"))
+		assert.Nil(t, ch.Validate())
+		assert.Equal(t, test.vnodes-10, ch.NumVnodes())
+	}
+}
+
+// TestCollisionProbe verifies that probed vnodes take the next free positions in the order servers were added
+func TestCollisionProbe(t *testing.T) {
+	ch := New(WithHashFunc(collidingHash), WithVnodeCount(1), WithCollisionStrategy(CollisionProbe))
+	ch.Add("c")
+	ch.Add("a")
+	ch.Add("b")
+	assert.Equal(t, []uint64{42, 43, 44}, ch.VnodePositions())
+	for i, server := range []string{"c", "a", "b"} {
+		owner, _ := ch.OwnerAt(uint64(42 + i))
+		assert.Equal(t, server, owner)
+	}
+}
+
+// TestCollisionSkip verifies that a server whose vnodes all collide keeps one
+func TestCollisionSkip(t *testing.T) {
+	ch := New(WithHashFunc(collidingHash), WithVnodeCount(1), WithCollisionStrategy(CollisionSkip))
+	ch.Add("a")
+	ch.Add("b")
+	assert.Equal(t, 1, ch.VnodeCount("b"))
+	assert.Nil(t, ch.Validate())
+	ch.AddAtPositions("c", []uint64{42, 100})
+	assert.Equal(t, []uint64{100}, ch.Positions("c"))
+	ch.AddAtPositions("d", []uint64{42, 100})
+	assert.Equal(t, []uint64{42}, ch.Positions("d"))
+	assert.Nil(t, ch.Validate())
+}
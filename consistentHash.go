@@ -2,24 +2,53 @@
 package consistentHash
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"sync"
-
-	"github.com/spaolacci/murmur3"
+	"sync/atomic"
 )
 
 var (
 	// ErrNoMembers occurs when trying to hash before any members are added
 	ErrNoMembers = errors.New("no members added")
+	// ErrEmptyRing is returned by lookups on a ring without members, it is the same error as ErrNoMembers
+	ErrEmptyRing = ErrNoMembers
 	// ErrNotEnoughMembers occurs when more members are asked for than are available
 	ErrNotEnoughMembers = errors.New("not enough members")
 	// ErrNotAvailableOnceMembersAdded occurs if any attempt is made to modify the vnode account once members are added
 	ErrNotAvailableOnceMembersAdded = errors.New("not available once members are added")
 	// ErrInvalidVnodeCount occurs if the vnode count is set to 0 or lower
 	ErrInvalidVnodeCount = errors.New("vnodeCount must be > 0")
+	// ErrNodeNotFound occurs when trying to remove a member that was never added
+	ErrNodeNotFound = errors.New("member not found")
+	// ErrNodeExists occurs when trying to add a member that has already been added
+	ErrNodeExists = errors.New("member already exists")
+	// ErrNoCapacity occurs when every member is at or above its load bound
+	ErrNoCapacity = errors.New("all members are at capacity")
+	// ErrNotEnoughZones occurs when more distinct zones are asked for than are available
+	ErrNotEnoughZones = errors.New("not enough zones")
+	// ErrInvalidWeight occurs if a weight would give a server less than one vnode
+	ErrInvalidWeight = errors.New("weight must give at least one vnode")
+	// ErrNoAvailableMembers occurs when every member has been excluded from a lookup
+	ErrNoAvailableMembers = errors.New("no members available")
+	// ErrInvalidRing is wrapped by the errors Validate returns when an internal invariant of the ring is broken
+	ErrInvalidRing = errors.New("invalid ring")
+	// ErrCorruptEncoding occurs when ReadFrom is given data that was not written by WriteTo
+	ErrCorruptEncoding = errors.New("corrupt ring encoding")
+	// ErrOutOfRange occurs with WithNoWrap when a key hashes past the last vnode
+	ErrOutOfRange = errors.New("key is past the last vnode")
+	// ErrLoadTrackingDisabled occurs when GetTracked or Release is used without WithLoadTracking
+	ErrLoadTrackingDisabled = errors.New("load tracking is not enabled")
+	// ErrKeyNotTracked occurs when releasing a key that GetTracked has not assigned
+	ErrKeyNotTracked = errors.New("key is not tracked")
+	// ErrInsufficientNodes occurs with WithMinNodes when a lookup is made on a ring with too few members
+	ErrInsufficientNodes = errors.New("not enough members to route")
+	// ErrNotShard occurs when GetShard maps a key to a member that was not added with AddShard
+	ErrNotShard = errors.New("member is not a shard")
 )
 
 const (
@@ -27,6 +56,12 @@ const (
 	DefaultVnodeCount = 200
 )
 
+// HashFunc maps a key onto the 64bit ring space
+type HashFunc func([]byte) uint64
+
+// Hash128Func maps a key onto the 128bit ring space used with WithHash128, returning the high and low 64 bits
+type Hash128Func func([]byte) (uint64, uint64)
+
 type vnode struct {
 	token   uint64
 	address string
@@ -35,27 +70,304 @@ type vnode struct {
 type vnodes []vnode
 
 // ConsistentHash holds the internal data structures for the hashing
+// It is safe for concurrent use, lookups take a read lock and membership changes take a write lock
 type ConsistentHash struct {
-	vnodes     vnodes
-	nodes      map[string]bool
+	// metrics comes first so its 64bit counters are aligned for atomic access on 32bit platforms
+	metrics counters
+	// positions holds the sorted vnode tokens and owners the server of the vnode at the same index
+	// keeping the tokens in their own dense slice keeps the binary search cache friendly
+	// owners stores an index into names rather than the name itself, so a vnode costs 4 bytes instead of a string header
+	positions []uint64
+	owners    []uint32
+	// replicas holds the replica index that generated the vnode at the same index, see VnodeInfo
+	replicas   []uint32
 	vnodeCount int
-	mutex      sync.Mutex
-	nodeCount  map[string]int
+	// names interns the server names owners refers to, ids maps a name back to its index and free lists the indexes
+	// of removed servers that can be reused
+	names []string
+	ids   map[string]uint32
+	free  []uint32
+	mutex sync.RWMutex
+	// nodeCount maps each member to the number of vnodes it was added with and doubles as the membership set
+	nodeCount map[string]int
+	// owned tracks the tokens each server currently occupies on the ring, maintained by insertVnode and removeVnode
+	owned map[string][]uint64
+	// zones maps members added with AddWithZone to their failure domain
+	zones map[string]string
+	// draining holds the members marked with SetDraining, which Get routes around
+	draining map[string]bool
+	hash     HashFunc
+	// ketama places vnodes the way libketama does, see WithKetamaCompat, taking pointsPerDigest points from each
+	// digest, see WithPointsPerDigest
+	ketama          bool
+	pointsPerDigest int
+	// collision decides what happens to a vnode placed where another already is, see WithCollisionStrategy
+	collision CollisionStrategy
+	// seed is mixed into every vnode key, see WithSeed
+	seed uint64
+	// noWrap makes keys past the last vnode an error instead of wrapping to the first, see WithNoWrap
+	noWrap bool
+	// minNodes is the number of members lookups require, see WithMinNodes
+	minNodes int
+	// uncounted leaves lookups out of the metrics, it is set on the private ring of a RingView so that readers of a
+	// snapshot never write to shared memory
+	uncounted bool
+	// replicaKey derives the key hashed to place each vnode, see WithReplicaKeyFunc
+	replicaKey func(server string, replica int) []byte
+	// hash128 gives vnodes and keys 128bit positions, see WithHash128, lows then holds the low 64 bits of the
+	// position of the vnode at the same index and is nil otherwise
+	hash128 Hash128Func
+	lows    []uint64
+	// tracker holds the keys assigned by GetTracked, see WithLoadTracking, and is nil otherwise
+	tracker *loadTracker
+	// onAdd and onRemove are the hooks registered with OnAdd and OnRemove
+	onAdd    []func(server string)
+	onRemove []func(server string)
 }
 
 // New creates a new consistentHash pointer and initializes all the necessary fields
-func New() *ConsistentHash {
+// Options are applied in order after the defaults are set
+func New(opts ...Option) *ConsistentHash {
 	ch := new(ConsistentHash)
-	ch.nodes = make(map[string]bool)
-	ch.vnodes = make(vnodes, 0)
+	ch.init()
+	for _, opt := range opts {
+		opt(ch)
+	}
+	return ch
+}
+
+// init sets the defaults New starts from
+func (ch *ConsistentHash) init() {
+	ch.positions = make([]uint64, 0)
+	ch.owners = make([]uint32, 0)
+	ch.replicas = make([]uint32, 0)
+	ch.ids = make(map[string]uint32)
 	ch.vnodeCount = DefaultVnodeCount
+	ch.pointsPerDigest = KetamaPointsPerDigest
 	ch.nodeCount = make(map[string]int)
+	ch.owned = make(map[string][]uint64)
+	ch.zones = make(map[string]string)
+	ch.draining = make(map[string]bool)
+	ch.hash = DefaultHash
+	ch.replicaKey = addressToKey
+}
+
+// NewFromWeights creates a new consistentHash like New with every server in weights added with its vnode count
+// Servers are added in name order so the ring does not depend on the map's iteration order, servers with a count
+// below 1 are left out
+func NewFromWeights(weights map[string]int, opts ...Option) *ConsistentHash {
+	ch := New(opts...)
+	addresses := make([]string, 0, len(weights))
+	for address, count := range weights {
+		if count >= 1 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		ch.add(address, weights[address])
+	}
 	return ch
 }
 
+// Clone returns a deep copy of the consistentHash, changes made to the copy do not affect the original
+func (ch *ConsistentHash) Clone() *ConsistentHash {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	clone := ch.blank()
+	clone.positions = append(make([]uint64, 0, len(ch.positions)), ch.positions...)
+	clone.owners = append(make([]uint32, 0, len(ch.owners)), ch.owners...)
+	clone.replicas = append(make([]uint32, 0, len(ch.replicas)), ch.replicas...)
+	if ch.lows != nil {
+		clone.lows = append(make([]uint64, 0, len(ch.lows)), ch.lows...)
+	}
+	clone.names = append([]string(nil), ch.names...)
+	clone.free = append([]uint32(nil), ch.free...)
+	for address, id := range ch.ids {
+		clone.ids[address] = id
+	}
+	for address, count := range ch.nodeCount {
+		clone.nodeCount[address] = count
+	}
+	for address, tokens := range ch.owned {
+		clone.owned[address] = append([]uint64(nil), tokens...)
+	}
+	for address, zone := range ch.zones {
+		clone.zones[address] = zone
+	}
+	for address := range ch.draining {
+		clone.draining[address] = true
+	}
+	if ch.tracker != nil {
+		ch.tracker.mutex.Lock()
+		for key, address := range ch.tracker.assigned {
+			clone.tracker.assigned[key] = address
+		}
+		for address, load := range ch.tracker.loads {
+			clone.tracker.loads[address] = load
+		}
+		ch.tracker.mutex.Unlock()
+	}
+	return clone
+}
+
+// Rebuild returns a new consistentHash with the same members, vnode counts, zones and draining servers, placed
+// with this ring's options followed by opts, so two placement schemes can be compared side by side
+// A lookup cannot simply swap the hash function, since the vnodes were placed with the old one, so to see where
+// a key lands under another hash query Rebuild(WithHashFunc(fn)) and compare with MigrationPlan
+// Members keep their own vnode counts, use Rebalance to change them, and hooks are not copied
+func (ch *ConsistentHash) Rebuild(opts ...Option) *ConsistentHash {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	next := ch.blank()
+	for _, opt := range opts {
+		opt(next)
+	}
+	for _, address := range ch.sortedMembers() {
+		next.add(address, ch.nodeCount[address])
+		if zone, found := ch.zones[address]; found {
+			next.zones[address] = zone
+		}
+		if ch.draining[address] {
+			next.draining[address] = true
+		}
+	}
+	return next
+}
+
+// Equal reports whether both rings have the same members with the same vnode counts and identical vnodes
+// The hash function and other options are not compared, only the resulting rings
+func (ch *ConsistentHash) Equal(other *ConsistentHash) bool {
+	if ch == other {
+		return true
+	}
+	// the other ring is copied first so that the two locks are never held at once
+	other.mutex.RLock()
+	nodeCount := make(map[string]int, len(other.nodeCount))
+	for address, count := range other.nodeCount {
+		nodeCount[address] = count
+	}
+	list := other.vnodes()
+	lows := append([]uint64(nil), other.lows...)
+	other.mutex.RUnlock()
+
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if len(ch.nodeCount) != len(nodeCount) || len(ch.positions) != len(list) || len(ch.lows) != len(lows) {
+		return false
+	}
+	for address, count := range ch.nodeCount {
+		if otherCount, found := nodeCount[address]; !found || otherCount != count {
+			return false
+		}
+	}
+	for i, vn := range list {
+		if ch.positions[i] != vn.token || ch.owner(i) != vn.address {
+			return false
+		}
+	}
+	for i, low := range lows {
+		if ch.lows[i] != low {
+			return false
+		}
+	}
+	return true
+}
+
+// blank returns an empty consistentHash with the same configuration
+// The caller must hold at least the read lock
+func (ch *ConsistentHash) blank() *ConsistentHash {
+	blank := New(WithHashFunc(ch.hash))
+	blank.vnodeCount = ch.vnodeCount
+	blank.ketama = ch.ketama
+	blank.pointsPerDigest = ch.pointsPerDigest
+	blank.collision = ch.collision
+	blank.seed = ch.seed
+	blank.noWrap = ch.noWrap
+	blank.minNodes = ch.minNodes
+	blank.replicaKey = ch.replicaKey
+	if ch.hash128 != nil {
+		blank.hash128 = ch.hash128
+		blank.lows = make([]uint64, 0)
+	}
+	if ch.tracker != nil {
+		blank.tracker = newLoadTracker()
+	}
+	return blank
+}
+
+// adopt replaces the membership and vnodes with those of next, which must not be used afterwards
+// The caller must hold the write lock
+func (ch *ConsistentHash) adopt(next *ConsistentHash) {
+	ch.positions = next.positions
+	ch.owners = next.owners
+	ch.replicas = next.replicas
+	ch.lows = next.lows
+	ch.names = next.names
+	ch.ids = next.ids
+	ch.free = next.free
+	ch.vnodeCount = next.vnodeCount
+	ch.nodeCount = next.nodeCount
+	ch.owned = next.owned
+	ch.zones = next.zones
+	ch.draining = next.draining
+	// the tracked keys are kept, apart from those of servers that are no longer members
+	if ch.tracker != nil {
+		ch.tracker.retain(func(address string) bool {
+			_, found := ch.nodeCount[address]
+			return found
+		})
+	}
+}
+
+// vnodes returns a copy of the ring as a vnode slice, only useful for debugging and tests
+func (ch *ConsistentHash) vnodes() vnodes {
+	list := make(vnodes, len(ch.positions))
+	for i := range list {
+		list[i] = vnode{ch.positions[i], ch.owner(i)}
+	}
+	return list
+}
+
+// owner returns the name of the server owning the vnode at index
+func (ch *ConsistentHash) owner(index int) string {
+	return ch.names[ch.owners[index]]
+}
+
+// intern returns the index of a server name in names, adding it if the server has no vnodes yet
+// The caller must hold the write lock
+func (ch *ConsistentHash) intern(address string) uint32 {
+	if id, found := ch.ids[address]; found {
+		return id
+	}
+	var id uint32
+	if len(ch.free) > 0 {
+		id = ch.free[len(ch.free)-1]
+		ch.free = ch.free[:len(ch.free)-1]
+		ch.names[id] = address
+	} else {
+		id = uint32(len(ch.names))
+		ch.names = append(ch.names, address)
+	}
+	ch.ids[address] = id
+	return id
+}
+
+// release frees the index of a server that no longer has any vnodes so it can be reused
+// The caller must hold the write lock
+func (ch *ConsistentHash) release(address string) {
+	id, found := ch.ids[address]
+	if !found {
+		return
+	}
+	delete(ch.ids, address)
+	ch.names[id] = ""
+	ch.free = append(ch.free, id)
+}
+
 // dumpVnodes prints the vnode slice to stdout, only useful for debugging
 func (ch *ConsistentHash) dumpVnodes() {
-	for _, vn := range ch.vnodes {
+	for _, vn := range ch.vnodes() {
 		fmt.Printf("%v\n", vn)
 	}
 }
@@ -66,10 +378,51 @@ func addressToKey(address string, increment int) []byte {
 	return []byte(strconv.Itoa(increment) + "=" + address)
 }
 
+// seeded prefixes a vnode key with the ring's seed, the default seed of 0 leaves the key as it is
+func (ch *ConsistentHash) seeded(key []byte) []byte {
+	if ch.seed == 0 {
+		return key
+	}
+	seeded := make([]byte, 8, 8+len(key))
+	binary.LittleEndian.PutUint64(seeded, ch.seed)
+	return append(seeded, key...)
+}
+
+// tokens returns the ring positions of the vnodes for a server added with nodeCount vnodes
+// lows holds the low 64 bits of each position with WithHash128 and is nil otherwise
+func (ch *ConsistentHash) tokens(address string, nodeCount int) (tokens, lows []uint64) {
+	if ch.ketama {
+		return ketamaTokens(address, nodeCount, ch.pointsPerDigest), nil
+	}
+	tokens = make([]uint64, nodeCount)
+	if ch.hash128 != nil {
+		lows = make([]uint64, nodeCount)
+	}
+	for i := range tokens {
+		key := ch.seeded(ch.replicaKey(address, i))
+		if ch.hash128 != nil {
+			tokens[i], lows[i] = ch.hash128(key)
+		} else {
+			tokens[i] = ch.hash(key)
+		}
+	}
+	return tokens, lows
+}
+
+// lowAt returns the low bits of the ith position returned by tokens
+func lowAt(lows []uint64, i int) uint64 {
+	if lows == nil {
+		return 0
+	}
+	return lows[i]
+}
+
 // SetVnodeCount sets the number of vnodes that will be added for every server
-// This must be called before any Add() calls
+// This must be called before any Add() calls, use Rebalance() to change the count of a populated ring
 func (ch *ConsistentHash) SetVnodeCount(count int) error {
-	if len(ch.nodes) > 0 {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	if len(ch.nodeCount) > 0 {
 		return ErrNotAvailableOnceMembersAdded
 	}
 	if count < 1 {
@@ -79,40 +432,354 @@ func (ch *ConsistentHash) SetVnodeCount(count int) error {
 	return nil
 }
 
-//AddWithNodeCount adds a server to the consistentHash
-func (ch *ConsistentHash) AddWithNodeCount(address string, nodeCount int) {
+// Rebalance sets the number of vnodes per server and rebuilds every existing server with that many vnodes,
+// including servers added with AddWithNodeCount
+// Unlike SetVnodeCount it can be called once members have been added
+func (ch *ConsistentHash) Rebalance(count int) error {
 	ch.mutex.Lock()
 	defer ch.mutex.Unlock()
-	// if the address has already been added, there is no work to do
-	if _, found := ch.nodes[address]; found {
-		return
+	if count < 1 {
+		return ErrInvalidVnodeCount
 	}
-	ch.nodes[address] = true
-	ch.nodeCount[address] = nodeCount
-	for i := 0; i < ch.nodeCount[address]; i++ {
-		token := murmur3.Sum64(addressToKey(address, i))
-		newVnode := vnode{token, address}
-		ch.insertVnode(newVnode)
+	next := ch.blank()
+	next.vnodeCount = count
+	for _, address := range ch.sortedMembers() {
+		next.add(address, count)
 	}
+	next.zones = ch.zones
+	next.draining = ch.draining
+	ch.adopt(next)
+	return nil
 }
 
-// Add adds a server to the consistentHash
-func (ch *ConsistentHash) Add(address string) {
-	ch.AddWithNodeCount(address, ch.vnodeCount)
+// SetNodeVnodeCount grows or shrinks a single server to newCount vnodes, leaving its other vnodes in place so only
+// the keys of the added or removed vnodes move, which allows traffic to be bled off a server gradually
+// Shrinking removes the vnodes with the highest positions, growing adds the server's unused replicas in order
+// The resulting positions are only kept in memory, decoding or Rebalance gives the server its usual newCount vnodes
+// ErrNodeNotFound is returned if the server is not a member
+func (ch *ConsistentHash) SetNodeVnodeCount(address string, newCount int) error {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	if newCount < 1 {
+		return ErrInvalidVnodeCount
+	}
+	if _, found := ch.nodeCount[address]; !found {
+		return ErrNodeNotFound
+	}
+	current := append([]uint64(nil), ch.owned[address]...)
+	if newCount < len(current) {
+		sort.Slice(current, func(i, j int) bool { return current[i] < current[j] })
+		for _, token := range current[newCount:] {
+			ch.removeVnode(vnode{token, address})
+		}
+	} else {
+		present := make(map[uint64]int, len(current))
+		for _, token := range current {
+			present[token]++
+		}
+		// at most len(current) of the candidates are already on the ring, so enough of them are unused
+		missing := newCount - len(current)
+		tokens, lows := ch.tokens(address, newCount+len(current))
+		for i, token := range tokens {
+			if missing == 0 {
+				break
+			}
+			if present[token] > 0 {
+				present[token]--
+				continue
+			}
+			ch.insert(vnode{token, address}, lowAt(lows, i), i)
+			missing--
+		}
+	}
+	ch.nodeCount[address] = newCount
+	return nil
 }
 
-// Remove removes a server from the consistentHash
-func (ch *ConsistentHash) Remove(address string) {
+// Reweight changes a server to newCount vnodes by adding or removing only its own replicas, so it ends up with
+// exactly the vnodes AddWithNodeCount(server, newCount) gives it and only the keys of those vnodes move
+// Unlike SetNodeVnodeCount, which trims the highest positions, the result does not depend on earlier changes and
+// survives decoding and Rebalance with the same counts
+// ErrInvalidVnodeCount is returned if newCount is below 1 and ErrNodeNotFound if the server is not a member
+func (ch *ConsistentHash) Reweight(address string, newCount int) error {
 	ch.mutex.Lock()
 	defer ch.mutex.Unlock()
-	if _, found := ch.nodes[address]; !found {
-		return
+	if newCount < 1 {
+		return ErrInvalidVnodeCount
+	}
+	if _, found := ch.nodeCount[address]; !found {
+		return ErrNodeNotFound
+	}
+	present := make(map[uint64]int, len(ch.owned[address]))
+	for _, token := range ch.owned[address] {
+		present[token]++
+	}
+	tokens, lows := ch.tokens(address, newCount)
+	var missing, missingLows []uint64
+	var missingReplicas []int
+	for i, token := range tokens {
+		if present[token] > 0 {
+			present[token]--
+		} else {
+			missing = append(missing, token)
+			missingReplicas = append(missingReplicas, i)
+			if lows != nil {
+				missingLows = append(missingLows, lows[i])
+			}
+		}
 	}
-	for i := 0; i < ch.nodeCount[address]; i++ {
-		token := murmur3.Sum64(addressToKey(address, i))
-		ch.removeVnode(token)
+	for token, count := range present {
+		for ; count > 0; count-- {
+			ch.removeVnode(vnode{token, address})
+		}
 	}
-	delete(ch.nodes, address)
+	ch.insertAll(address, missing, missingLows, missingReplicas)
+	ch.nodeCount[address] = newCount
+	return nil
+}
+
+// AddWithNodeCount adds a server to the consistentHash with nodeCount vnodes
+// ErrNodeExists is returned if the server has already been added, and the ring is left unchanged
+func (ch *ConsistentHash) AddWithNodeCount(address string, nodeCount int) error {
+	return ch.update(func() ([]string, []string, error) {
+		return added(address, ch.add(address, nodeCount))
+	})
+}
+
+// Add adds a server to the consistentHash with the configured vnode count
+// ErrNodeExists is returned if the server has already been added
+func (ch *ConsistentHash) Add(address string) error {
+	return ch.update(func() ([]string, []string, error) {
+		return added(address, ch.add(address, ch.vnodeCount))
+	})
+}
+
+// AddWithWeight adds a server with weight times the configured vnode count of vnodes, rounded to the nearest integer
+// A weight of 2.0 gives the server twice the vnodes and so roughly twice the keys of a server added with Add
+// ErrInvalidWeight is returned if the weight would give the server fewer than 1 vnode
+func (ch *ConsistentHash) AddWithWeight(address string, weight float64) error {
+	return ch.update(func() ([]string, []string, error) {
+		count := math.Round(weight * float64(ch.vnodeCount))
+		if !(count >= 1) || count > math.MaxInt32 {
+			return nil, nil, ErrInvalidWeight
+		}
+		return added(address, ch.add(address, int(count)))
+	})
+}
+
+// AddAtPositions adds a server with one vnode at each of the given positions instead of hashed ones, which gives
+// tests and special layouts full control over which keys it owns
+// With WithHash128 the positions are the high 64 bits and the low bits are 0
+// Only membership is serialized, so the server gets hashed positions once the ring is decoded or rebuilt by Rebalance
+// ErrInvalidVnodeCount is returned if positions is empty and ErrNodeExists if the server has already been added
+func (ch *ConsistentHash) AddAtPositions(address string, positions []uint64) error {
+	return ch.update(func() ([]string, []string, error) {
+		if len(positions) == 0 {
+			return nil, nil, ErrInvalidVnodeCount
+		}
+		if _, found := ch.nodeCount[address]; found {
+			return nil, nil, ErrNodeExists
+		}
+		ch.nodeCount[address] = len(positions)
+		ch.insertAll(address, positions, nil, nil)
+		return []string{address}, nil, nil
+	})
+}
+
+// add places nodeCount vnodes for a new server
+// The caller must hold the write lock
+func (ch *ConsistentHash) add(address string, nodeCount int) error {
+	if nodeCount < 1 {
+		return ErrInvalidVnodeCount
+	}
+	if _, found := ch.nodeCount[address]; found {
+		return ErrNodeExists
+	}
+	ch.nodeCount[address] = nodeCount
+	tokens, lows := ch.tokens(address, nodeCount)
+	ch.insertAll(address, tokens, lows, nil)
+	return nil
+}
+
+// Clear removes every server from the consistentHash, the configured vnode count and hash function are kept
+func (ch *ConsistentHash) Clear() {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	ch.adopt(ch.blank())
+}
+
+// Contains reports whether a server is a member of the consistentHash
+func (ch *ConsistentHash) Contains(address string) bool {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	_, found := ch.nodeCount[address]
+	return found
+}
+
+// Remove removes a server from the consistentHash
+// ErrNodeNotFound is returned if the server is not a member
+func (ch *ConsistentHash) Remove(address string) error {
+	return ch.update(func() ([]string, []string, error) {
+		return removed(address, ch.remove(address))
+	})
+}
+
+// remove takes a server and all of its vnodes off the ring
+// The caller must hold the write lock
+func (ch *ConsistentHash) remove(address string) error {
+	if _, found := ch.nodeCount[address]; !found {
+		return ErrNodeNotFound
+	}
+	ch.removeOwner(address)
+	delete(ch.nodeCount, address)
+	delete(ch.zones, address)
+	delete(ch.draining, address)
+	if ch.tracker != nil {
+		ch.tracker.retain(func(assigned string) bool { return assigned != address })
+	}
+	return nil
+}
+
+// Rename gives the vnodes of the server old to the server new without moving them, every key maps to the same
+// vnode as before and only the returned name changes, its zone moves with it
+// OnRemove hooks are called with old and OnAdd hooks with new
+// Only membership is serialized, so a renamed server gets the positions of its new name once the ring is decoded
+// or rebuilt by Rebalance
+// ErrNodeNotFound is returned if old is not a member and ErrNodeExists if new already is
+func (ch *ConsistentHash) Rename(old, new string) error {
+	return ch.update(func() ([]string, []string, error) {
+		if err := ch.rename(old, new); err != nil {
+			return nil, nil, err
+		}
+		return []string{new}, []string{old}, nil
+	})
+}
+
+// Replace swaps the server old for new in one step, new gets the vnode count old was added with and its own hashed
+// positions, so readers see either old or new but never a ring without either
+// Unlike Rename keys move as if old had been removed and new added, its zone and draining state are not carried over
+// OnRemove hooks are called with old and OnAdd hooks with new
+// ErrNodeNotFound is returned if old is not a member and ErrNodeExists if new already is
+func (ch *ConsistentHash) Replace(old, new string) error {
+	return ch.update(func() ([]string, []string, error) {
+		count, found := ch.nodeCount[old]
+		if !found {
+			return nil, nil, ErrNodeNotFound
+		}
+		if _, found := ch.nodeCount[new]; found {
+			return nil, nil, ErrNodeExists
+		}
+		ch.remove(old)
+		ch.add(new, count)
+		return []string{new}, []string{old}, nil
+	})
+}
+
+// rename retargets the vnodes of old to new
+// The caller must hold the write lock
+func (ch *ConsistentHash) rename(old, new string) error {
+	if _, found := ch.nodeCount[old]; !found {
+		return ErrNodeNotFound
+	}
+	if _, found := ch.nodeCount[new]; found {
+		return ErrNodeExists
+	}
+	id := ch.ids[old]
+	ch.names[id] = new
+	ch.ids[new] = id
+	delete(ch.ids, old)
+	// colliding vnodes are ordered by address, so restore that order within each run of equal tokens
+	for i := 1; i < len(ch.owners); i++ {
+		for j := i; j > 0 && ch.positions[j-1] == ch.positions[j] && ch.less(j, j-1); j-- {
+			ch.owners[j-1], ch.owners[j] = ch.owners[j], ch.owners[j-1]
+			ch.replicas[j-1], ch.replicas[j] = ch.replicas[j], ch.replicas[j-1]
+		}
+	}
+	ch.nodeCount[new] = ch.nodeCount[old]
+	delete(ch.nodeCount, old)
+	ch.owned[new] = ch.owned[old]
+	delete(ch.owned, old)
+	if zone, found := ch.zones[old]; found {
+		ch.zones[new] = zone
+		delete(ch.zones, old)
+	}
+	if ch.draining[old] {
+		ch.draining[new] = true
+		delete(ch.draining, old)
+	}
+	if ch.tracker != nil {
+		ch.tracker.rename(old, new)
+	}
+	return nil
+}
+
+// VnodeCount returns the number of vnodes a server currently has on the ring, or 0 if it is not a member
+func (ch *ConsistentHash) VnodeCount(address string) int {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return len(ch.owned[address])
+}
+
+// Positions returns the sorted tokens a server occupies on the ring, or nil if it is not a member
+func (ch *ConsistentHash) Positions(address string) []uint64 {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	tokens, found := ch.owned[address]
+	if !found {
+		return nil
+	}
+	positions := append(make([]uint64, 0, len(tokens)), tokens...)
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+	return positions
+}
+
+// Size returns the number of servers in the consistentHash, not counting vnodes
+func (ch *ConsistentHash) Size() int {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return len(ch.nodeCount)
+}
+
+// NumVnodes returns the total number of vnodes on the ring
+// Each vnode costs about 24 bytes, its position twice at 8 bytes each, a 4 byte index of its owner and its 4 byte
+// replica index, plus 8 bytes for the low bits of its position with WithHash128, not counting spare slice capacity
+// or the server names, which are only stored once
+func (ch *ConsistentHash) NumVnodes() int {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return len(ch.positions)
+}
+
+// VnodePositions returns a sorted copy of the positions of every vnode on the ring, vnodes sharing a position are
+// listed once each, with OwnerAt it shows the whole layout and two rings can be diffed quickly
+// With WithHash128 these are the high 64 bits of each position
+func (ch *ConsistentHash) VnodePositions() []uint64 {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return append(make([]uint64, 0, len(ch.positions)), ch.positions...)
+}
+
+// IsEmpty reports whether the consistentHash has no members
+func (ch *ConsistentHash) IsEmpty() bool {
+	return ch.Size() == 0
+}
+
+// Members returns the servers currently in the consistentHash, sorted by name
+func (ch *ConsistentHash) Members() []string {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return ch.sortedMembers()
+}
+
+// sortedMembers is Members without the locking
+// The caller must hold at least the read lock
+func (ch *ConsistentHash) sortedMembers() []string {
+	members := make([]string, 0, len(ch.nodeCount))
+	for address := range ch.nodeCount {
+		members = append(members, address)
+	}
+	sort.Strings(members)
+	return members
 }
 
 func (v *vnode) String() string {
@@ -120,20 +787,140 @@ func (v *vnode) String() string {
 }
 
 // Get finds the closest member for a given key
+// It does not allocate, the key is hashed in place and the result is the interned server name
 func (ch *ConsistentHash) Get(key []byte) (string, error) {
-	ch.mutex.Lock()
-	defer ch.mutex.Unlock()
-	if len(ch.vnodes) == 0 {
-		return "", ErrNoMembers
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index, err := ch.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return ch.primary(index)
+}
+
+// GetOrDefault finds the closest member for a given key like Get, returning fallback instead of an error when the
+// ring is empty or the key cannot be mapped
+func (ch *ConsistentHash) GetOrDefault(key []byte, fallback string) string {
+	server, err := ch.Get(key)
+	if err != nil {
+		return fallback
+	}
+	return server
+}
+
+// HashKey returns the hash the ring uses to place a key, so keys can be hashed once and routed later with GetByHash
+// With WithHash128 it is the high 64 bits of the key's position
+func (ch *ConsistentHash) HashKey(key []byte) uint64 {
+	return ch.hash(key)
+}
+
+// GetByHash finds the closest member for a key that has already been hashed with the ring's hash function
+// GetByHash(hash(key)) returns the same member as Get(key), with WithHash128 hash is the high 64 bits of the position
+func (ch *ConsistentHash) GetByHash(hash uint64) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if err := ch.routable(); err != nil {
+		return "", err
 	}
-	token := murmur3.Sum64(key)
-	return ch.vnodes[ch.closest(token)].address, nil
+	index, err := ch.wrap(ch.index(hash))
+	if err != nil {
+		return "", err
+	}
+	return ch.primary(index)
+}
+
+// OwnerAt returns the server owning a ring position, the server of the first vnode at or after it, wrapping around
+// to the first vnode, which is useful to render which server owns each part of the ring
+// Unlike GetByHash it does not skip draining servers, with WithHash128 position is the high 64 bits
+func (ch *ConsistentHash) OwnerAt(position uint64) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if err := ch.routable(); err != nil {
+		return "", err
+	}
+	index, err := ch.wrap(ch.index(position))
+	if err != nil {
+		return "", err
+	}
+	return ch.owner(index), nil
+}
+
+// VnodeInfo returns the server and replica index of the vnode at exactly position, with ok false if there is none
+// The replica index is the i passed to the ReplicaKeyFunc, or the index of the point with WithKetamaCompat, so it
+// can be matched against placements computed outside this package
+// Vnodes added with AddAtPositions use the index of their position in the list given, with WithHash128 position is
+// the high 64 bits and vnodes sharing a position report the first in ring order
+func (ch *ConsistentHash) VnodeInfo(position uint64) (server string, replica int, ok bool) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index := ch.index(position)
+	if index == len(ch.positions) || ch.positions[index] != position {
+		return "", 0, false
+	}
+	return ch.owner(index), int(ch.replicas[index]), true
+}
+
+// GetDetailed finds the closest member for a given key like Get, also returning the position of the vnode it
+// landed on and the hash of the key, which is useful to see why keys map where they do
+// Like Get it skips draining servers, the position is then that of the first vnode of the server actually chosen
+func (ch *ConsistentHash) GetDetailed(key []byte) (server string, vnodePos uint64, keyHash uint64, err error) {
+	keyHash = ch.hash(key)
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index, err := ch.lookup(key)
+	if err == nil {
+		index, err = ch.serving(index)
+	}
+	if err != nil {
+		return "", 0, keyHash, err
+	}
+	return ch.owner(index), ch.positions[index], keyHash, nil
+}
+
+// GetBatch finds the closest member for each key, taking the read lock once for the whole batch
+// The result is in the same order as keys
+func (ch *ConsistentHash) GetBatch(keys [][]byte) ([]string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if err := ch.routable(); err != nil {
+		return nil, err
+	}
+	servers := make([]string, len(keys))
+	for i, key := range keys {
+		index, err := ch.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		if servers[i], err = ch.primary(index); err != nil {
+			return nil, err
+		}
+	}
+	return servers, nil
+}
+
+// KeysOwnedBy returns the keys Get maps to server, in the order they were given, so a draining server owns none
+// It is useful to see which keys are affected before taking a server down
+func (ch *ConsistentHash) KeysOwnedBy(server string, keys [][]byte) [][]byte {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	var owned [][]byte
+	for _, key := range keys {
+		index, err := ch.lookup(key)
+		if err != nil {
+			continue
+		}
+		if owner, err := ch.primary(index); err == nil && owner == server {
+			owned = append(owned, key)
+		}
+	}
+	return owned
 }
 
 // Get2 finds the closest 2 members for a given key and is just a helper function
-// calling into GetN
+// calling into GetN, so the second member is always a different server than the first
+// ErrNotEnoughMembers is returned if there is only one member
 func (ch *ConsistentHash) Get2(key []byte) (string, string, error) {
-	// don't use the mutex since GetN will use it
+	// don't take the read lock since GetN will take it
 	servers, err := ch.GetN(key, 2)
 	if err != nil {
 		return "", "", err
@@ -142,65 +929,525 @@ func (ch *ConsistentHash) Get2(key []byte) (string, string, error) {
 
 }
 
-// GetN finds the closest N members for a given key
+// GetN finds the closest N distinct members for a given key, in ring order
+// The list only depends on the vnodes between the key and the vnode of its Nth member, so adding a server leaves
+// the list of every other key unchanged and for the rest just inserts the new server, dropping the last member
+// Vnodes colliding on a position are ordered by server name, so the list is the same whatever order servers were
+// added in, identical vnodes of one server are interchangeable and never produce a server twice
+// ErrEmptyRing is returned if there are no members and ErrNotEnoughMembers if there are fewer than N
 func (ch *ConsistentHash) GetN(key []byte, count int) ([]string, error) {
-	ch.mutex.Lock()
-	defer ch.mutex.Unlock()
-	if len(ch.nodes) < count {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return ch.getN(key, count)
+}
+
+// Secondaries finds the N distinct members that follow the primary of a key in ring order, GetN(key, N+1) without
+// its first member, for instance to read repair the replicas
+// ErrEmptyRing is returned if there are no members and ErrNotEnoughMembers if there are not N besides the primary
+func (ch *ConsistentHash) Secondaries(key []byte, count int) ([]string, error) {
+	if count < 1 {
+		count = 0
+	}
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	addresses, err := ch.getN(key, count+1)
+	if err != nil {
+		return nil, err
+	}
+	return addresses[1:], nil
+}
+
+// GetNByDistance finds the closest N distinct members for a given key ordered by distance, closest first
+// The distance of a member is how far clockwise from the key's position its nearest vnode is, so the order is the
+// ring order of GetN, members at the same distance are ordered by name
+// It returns the same errors as GetN
+func (ch *ConsistentHash) GetNByDistance(key []byte, count int) ([]string, error) {
+	return ch.GetN(key, count)
+}
+
+// GetAllOrdered returns every member once, in ring order starting at the key's position, so scatter gather
+// requests can be sent to the closest members first
+// The first member is the one Get returns, so draining servers ahead of it on the ring are moved to just after it
+// The list is empty if the ring has no members
+func (ch *ConsistentHash) GetAllOrdered(key []byte) []string {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index, err := ch.lookup(key)
+	if err != nil {
+		return []string{}
+	}
+	addresses := ch.distinct(index, len(ch.nodeCount))
+	first, err := ch.primary(index)
+	if err != nil {
+		return addresses
+	}
+	for i, address := range addresses {
+		if address == first {
+			copy(addresses[1:i+1], addresses[:i])
+			addresses[0] = first
+			break
+		}
+	}
+	return addresses
+}
+
+// GetNInto is GetN writing into dst instead of allocating a new slice, it fills dst with up to len(dst) distinct
+// members in GetN order and returns how many it wrote, fewer than len(dst) if there are not enough members
+// Reusing dst across calls makes replica lookups allocation free
+// ErrEmptyRing is returned if there are no members
+func (ch *ConsistentHash) GetNInto(key []byte, dst []string) (int, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index, err := ch.lookup(key)
+	if err != nil || len(dst) == 0 {
+		return 0, err
+	}
+	count := 0
+	ch.successors(index, func(address string) bool {
+		// dst is short, so scanning it is cheaper than a set and allocates nothing
+		for _, picked := range dst[:count] {
+			if picked == address {
+				return true
+			}
+		}
+		dst[count] = address
+		count++
+		return count < len(dst) && count < len(ch.nodeCount)
+	})
+	return count, nil
+}
+
+// GetUpTo is GetN that returns as many distinct members as there are, up to count, instead of failing
+// It never returns an error, the list is empty if the ring is or if the key cannot be mapped with WithNoWrap
+func (ch *ConsistentHash) GetUpTo(key []byte, count int) []string {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if count > len(ch.nodeCount) {
+		count = len(ch.nodeCount)
+	}
+	addresses, err := ch.getN(key, count)
+	if err != nil {
+		return []string{}
+	}
+	return addresses
+}
+
+// getN is GetN without the locking
+// The caller must hold at least the read lock
+func (ch *ConsistentHash) getN(key []byte, count int) ([]string, error) {
+	if err := ch.routable(); err != nil {
+		return nil, err
+	}
+	if len(ch.nodeCount) < count {
 		return nil, ErrNotEnoughMembers
 	}
-	token := murmur3.Sum64(key)
+	if count < 1 {
+		return []string{}, nil
+	}
+	index, err := ch.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	addresses := ch.distinct(index, count)
+	if len(addresses) < count {
+		return nil, ErrNotEnoughMembers
+	}
+	return addresses, nil
+}
+
+// distinct returns up to count distinct owners of the vnodes at and after index in ring order
+func (ch *ConsistentHash) distinct(index, count int) []string {
 	addressMap := make(map[string]bool)
-	addresses := make([]string, count)
-	index := ch.closest(token)
-	found := 0
-	for found < count {
-		if exists := addressMap[ch.vnodes[index].address]; !exists {
-			addressMap[ch.vnodes[index].address] = true
-			addresses[found] = ch.vnodes[index].address
-			found++
+	addresses := make([]string, 0, count)
+	// vnodes of servers that were already picked are skipped, so adjacent vnodes of one server never produce duplicates
+	ch.successors(index, func(address string) bool {
+		if !addressMap[address] {
+			addressMap[address] = true
+			addresses = append(addresses, address)
+		}
+		return len(addresses) < count
+	})
+	return addresses
+}
+
+// GetWithFallbacks finds the closest member for a given key plus the next fallbacks distinct members in ring order,
+// which are the members that take over the key, in priority order, if the primary goes away
+// It is GetN(key, fallbacks+1) split into the primary and the rest
+func (ch *ConsistentHash) GetWithFallbacks(key []byte, fallbacks int) (string, []string, error) {
+	servers, err := ch.GetN(key, fallbacks+1)
+	if err != nil {
+		return "", nil, err
+	}
+	return servers[0], servers[1:], nil
+}
+
+// Placement is where a key is stored, its primary server and the distinct servers holding its replicas
+type Placement struct {
+	Primary string
+	// Replicas are in ring order, the order in which they take over the key if the primary goes away
+	Replicas []string
+}
+
+// GetPlacement finds the primary and the next replicas distinct members for a given key, a negative replicas is
+// treated as 0
+// It is GetN(key, replicas+1) split into the primary and its replicas, so ErrNotEnoughMembers is returned if there
+// are not replicas+1 members
+func (ch *ConsistentHash) GetPlacement(key []byte, replicas int) (Placement, error) {
+	if replicas < 0 {
+		replicas = 0
+	}
+	servers, err := ch.GetN(key, replicas+1)
+	if err != nil {
+		return Placement{}, err
+	}
+	return Placement{Primary: servers[0], Replicas: servers[1:]}, nil
+}
+
+// GetNFunc finds the closest members for a given key where the number of members is chosen per key by count
+// It behaves like GetN(key, count(key))
+func (ch *ConsistentHash) GetNFunc(key []byte, count func(key []byte) int) ([]string, error) {
+	return ch.GetN(key, count(key))
+}
+
+// GetExcluding finds the closest member for a given key that is not in excluded, such as servers failing health checks
+// Keys of an excluded server go to the next member in ring order and return to it once it is no longer excluded,
+// unlike Remove the placement of every other key is unaffected
+// ErrNoAvailableMembers is returned if every member is excluded
+func (ch *ConsistentHash) GetExcluding(key []byte, excluded map[string]bool) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index, err := ch.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	found, ok := "", false
+	ch.successors(index, func(address string) bool {
+		if !excluded[address] {
+			found, ok = address, true
+		}
+		return !ok
+	})
+	if !ok {
+		return "", ErrNoAvailableMembers
+	}
+	return found, nil
+}
+
+// Walk calls fn with the position and server of every vnode in ring order, stopping early if fn returns false
+// The read lock is held during the walk, so fn must not modify the consistentHash
+func (ch *ConsistentHash) Walk(fn func(position uint64, server string) bool) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	for i, position := range ch.positions {
+		if !fn(position, ch.owner(i)) {
+			return
+		}
+	}
+}
+
+// successors calls fn with the owner of each vnode, starting at index and walking clockwise once around the ring
+// The walk stops early if fn returns false
+func (ch *ConsistentHash) successors(index int, fn func(address string) bool) {
+	for i := 0; i < len(ch.owners); i++ {
+		if !fn(ch.owner(index)) {
+			return
 		}
 		index++
-		if index == len(ch.vnodes) {
+		if index == len(ch.owners) {
 			index = 0
 		}
 	}
-	return addresses, nil
+}
 
+// GetString is Get for a string key
+func (ch *ConsistentHash) GetString(key string) (string, error) {
+	return ch.Get([]byte(key))
 }
 
-// removeVnode removes a vnode from the ring
-func (ch *ConsistentHash) removeVnode(token uint64) {
-	index := ch.index(token)
-	if index == len(ch.vnodes) {
-		ch.vnodes = ch.vnodes[:index-1]
+// GetNamespaced is Get for a key within a namespace, the namespace is mixed into the hashed key so the keys of
+// each namespace are spread independently and a hot namespace does not concentrate on the servers of another
+// The same namespace and key always map to the same server, see KeyFromNamespace
+func (ch *ConsistentHash) GetNamespaced(namespace string, key []byte) (string, error) {
+	return ch.Get(KeyFromNamespace(namespace, key))
+}
+
+// GetUint64 is Get for an integer key, which is hashed as its 8 byte little-endian encoding, see KeyFromUint64
+// The encoding is part of the API and will not change, so integer keys keep mapping to the same servers
+func (ch *ConsistentHash) GetUint64(key uint64) (string, error) {
+	return ch.Get(KeyFromUint64(key))
+}
+
+// GetInt is GetUint64 for an int key, negative keys are encoded in two's complement, so GetInt(-1) is
+// GetUint64(math.MaxUint64) on every platform
+func (ch *ConsistentHash) GetInt(key int) (string, error) {
+	return ch.GetUint64(uint64(int64(key)))
+}
+
+// Get2String is Get2 for a string key
+func (ch *ConsistentHash) Get2String(key string) (string, string, error) {
+	return ch.Get2([]byte(key))
+}
+
+// GetNString is GetN for a string key
+func (ch *ConsistentHash) GetNString(key string, count int) ([]string, error) {
+	return ch.GetN([]byte(key), count)
+}
+
+// removeVnode removes a vnode from the ring, doing nothing if it is not present
+// The caller must hold the write lock
+func (ch *ConsistentHash) removeVnode(vn vnode) {
+	index := ch.find(vn)
+	if index == -1 {
 		return
 	}
-	ch.vnodes = append(ch.vnodes[:index], ch.vnodes[index+1:]...)
+	ch.positions = append(ch.positions[:index], ch.positions[index+1:]...)
+	ch.owners = append(ch.owners[:index], ch.owners[index+1:]...)
+	ch.replicas = append(ch.replicas[:index], ch.replicas[index+1:]...)
+	if ch.lows != nil {
+		ch.lows = append(ch.lows[:index], ch.lows[index+1:]...)
+	}
+	tokens := ch.owned[vn.address]
+	for i, token := range tokens {
+		if token == vn.token {
+			tokens[i] = tokens[len(tokens)-1]
+			tokens = tokens[:len(tokens)-1]
+			break
+		}
+	}
+	if len(tokens) == 0 {
+		delete(ch.owned, vn.address)
+		ch.release(vn.address)
+	} else {
+		ch.owned[vn.address] = tokens
+	}
+}
+
+// removeOwner takes every vnode of a server off the ring in a single pass, using the tokens tracked in owned
+// to find them instead of scanning for ownership
+// The caller must hold the write lock
+func (ch *ConsistentHash) removeOwner(address string) {
+	tokens := append([]uint64(nil), ch.owned[address]...)
+	if len(tokens) == 0 {
+		return
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	indexes := make([]int, 0, len(tokens))
+	id := ch.ids[address]
+	for i, token := range tokens {
+		// every vnode of the server in a run of equal tokens is collected the first time the token is seen
+		if i > 0 && token == tokens[i-1] {
+			continue
+		}
+		for index := ch.index(token); index < len(ch.positions) && ch.positions[index] == token; index++ {
+			if ch.owners[index] == id {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+	kept := indexes[0]
+	for i, next := indexes[0], 0; i < len(ch.positions); i++ {
+		if next < len(indexes) && i == indexes[next] {
+			next++
+			continue
+		}
+		ch.positions[kept] = ch.positions[i]
+		ch.owners[kept] = ch.owners[i]
+		ch.replicas[kept] = ch.replicas[i]
+		if ch.lows != nil {
+			ch.lows[kept] = ch.lows[i]
+		}
+		kept++
+	}
+	ch.positions = ch.positions[:kept]
+	ch.owners = ch.owners[:kept]
+	ch.replicas = ch.replicas[:kept]
+	if ch.lows != nil {
+		ch.lows = ch.lows[:kept]
+	}
+	delete(ch.owned, address)
+	ch.release(address)
 }
 
 // insertVnode adds a vnode into the appropriate location of the ring
+// vnodes that collide on the same token are kept side by side ordered by address, so no vnode is lost and the
+// resulting order does not depend on the order servers were added in
+// The caller must hold the write lock
 func (ch *ConsistentHash) insertVnode(vn vnode) {
-	index := ch.index(vn.token)
-	ch.vnodes = append(ch.vnodes[:index], append(vnodes{vn}, ch.vnodes[index:]...)...)
+	ch.insert(vn, 0, 0)
+}
+
+// insert adds the vnode of a replica with the given low 64 bits of its position, which are ignored unless the ring
+// uses WithHash128
+// A vnode colliding with one already on the ring is resolved by the ring's CollisionStrategy
+// The caller must hold the write lock
+func (ch *ConsistentHash) insert(vn vnode, low uint64, replica int) {
+	switch ch.collision {
+	case CollisionProbe:
+		for ch.occupied(vn.token, low) {
+			vn.token++
+		}
+	case CollisionSkip:
+		if ch.occupied(vn.token, low) {
+			return
+		}
+	}
+	ch.place(vn, low, replica)
+}
+
+// insertAll adds a vnode at each of tokens for a server, lows holding their low bits or nil and replicas their
+// replica indexes, or nil if the ith token is replica i
+// If CollisionSkip drops all of them and the server has no other vnode, the first is kept anyway so that every
+// member stays on the ring
+// The caller must hold the write lock
+func (ch *ConsistentHash) insertAll(address string, tokens, lows []uint64, replicas []int) {
+	for i, token := range tokens {
+		ch.insert(vnode{token, address}, lowAt(lows, i), replicaAt(replicas, i))
+	}
+	if len(ch.owned[address]) == 0 && len(tokens) > 0 {
+		ch.place(vnode{tokens[0], address}, lowAt(lows, 0), replicaAt(replicas, 0))
+	}
+}
+
+// replicaAt returns the replica index of the ith token passed to insertAll
+func replicaAt(replicas []int, i int) int {
+	if replicas == nil {
+		return i
+	}
+	return replicas[i]
+}
+
+// place adds a vnode next to any vnodes at the same position, ordered by address
+// The caller must hold the write lock
+func (ch *ConsistentHash) place(vn vnode, low uint64, replica int) {
+	index := ch.slot(vn, low)
+	ch.positions = append(ch.positions, 0)
+	copy(ch.positions[index+1:], ch.positions[index:])
+	ch.positions[index] = vn.token
+	if ch.lows != nil {
+		ch.lows = append(ch.lows, 0)
+		copy(ch.lows[index+1:], ch.lows[index:])
+		ch.lows[index] = low
+	}
+	ch.owners = append(ch.owners, 0)
+	copy(ch.owners[index+1:], ch.owners[index:])
+	ch.owners[index] = ch.intern(vn.address)
+	ch.replicas = append(ch.replicas, 0)
+	copy(ch.replicas[index+1:], ch.replicas[index:])
+	ch.replicas[index] = uint32(replica)
+	ch.owned[vn.address] = append(ch.owned[vn.address], vn.token)
+}
+
+// occupied reports whether a vnode is already at token with the given low bits
+func (ch *ConsistentHash) occupied(token, low uint64) bool {
+	index := ch.slot(vnode{token, ""}, low)
+	return index < len(ch.positions) && ch.positions[index] == token && (ch.lows == nil || ch.lows[index] == low)
+}
+
+// slot returns the index of the first vnode ordered at or after vn with the given low bits
+// vnodes are ordered by token, then by the low bits of their position with WithHash128 and then by address
+func (ch *ConsistentHash) slot(vn vnode, low uint64) int {
+	return sort.Search(len(ch.positions), func(i int) bool {
+		return !ch.before(i, vn.token, low, vn.address)
+	})
+}
+
+// before reports whether the vnode at index is ordered before a vnode at token and low owned by address
+func (ch *ConsistentHash) before(index int, token, low uint64, address string) bool {
+	if ch.positions[index] != token {
+		return ch.positions[index] < token
+	}
+	if ch.lows != nil && ch.lows[index] != low {
+		return ch.lows[index] < low
+	}
+	return ch.owner(index) < address
+}
+
+// less reports whether the vnode at i is ordered before the vnode at j
+func (ch *ConsistentHash) less(i, j int) bool {
+	var low uint64
+	if ch.lows != nil {
+		low = ch.lows[j]
+	}
+	return ch.before(i, ch.positions[j], low, ch.owner(j))
+}
+
+// find returns the index of a vnode at vn's token owned by vn's address, or -1 if there is none
+func (ch *ConsistentHash) find(vn vnode) int {
+	for index := ch.index(vn.token); index < len(ch.positions) && ch.positions[index] == vn.token; index++ {
+		if ch.owner(index) == vn.address {
+			return index
+		}
+	}
+	return -1
+}
+
+// lookup returns the index of the vnode a key maps to, the first at or after the key's position
+// Keys past the last vnode wrap around to the first, unless the ring uses WithNoWrap and ErrOutOfRange is returned
+// ErrEmptyRing is returned if there are no vnodes, or ErrInsufficientNodes before it with WithMinNodes
+// The caller must hold at least the read lock
+func (ch *ConsistentHash) lookup(key []byte) (int, error) {
+	if err := ch.routable(); err != nil {
+		return 0, err
+	}
+	return ch.wrap(ch.seek(key))
+}
+
+// routable is checked first by every lookup, it returns ErrInsufficientNodes while the ring has fewer members than
+// WithMinNodes requires, even if it has none, and ErrEmptyRing if there are no vnodes
+func (ch *ConsistentHash) routable() error {
+	if len(ch.nodeCount) < ch.minNodes {
+		return ErrInsufficientNodes
+	}
+	if len(ch.positions) == 0 {
+		return ErrEmptyRing
+	}
+	return nil
+}
+
+// seek returns the index of the first vnode at or after the key's position, or the number of vnodes if there is none
+func (ch *ConsistentHash) seek(key []byte) int {
+	if ch.hash128 == nil {
+		return ch.index(ch.hash(key))
+	}
+	token, low := ch.hash128(key)
+	return sort.Search(len(ch.positions), func(i int) bool {
+		return !ch.before(i, token, low, "")
+	})
+}
+
+// wrap turns an index past the last vnode into the first vnode, or ErrOutOfRange with WithNoWrap
+// Every lookup goes through wrap, so it also counts lookups for Metrics
+func (ch *ConsistentHash) wrap(index int) (int, error) {
+	if !ch.uncounted {
+		atomic.AddUint64(&ch.metrics.lookups, 1)
+	}
+	if index < len(ch.positions) {
+		return index, nil
+	}
+	if !ch.uncounted {
+		atomic.AddUint64(&ch.metrics.wraps, 1)
+	}
+	if ch.noWrap {
+		return 0, ErrOutOfRange
+	}
+	return 0, nil
 }
 
 // index returns the position where we should insert a new vnode
 // differs from closest in that if the new token is bigger than the current highest token
 // the index returned should be the end
 func (ch *ConsistentHash) index(token uint64) int {
-	index := sort.Search(len(ch.vnodes), func(i int) bool {
-		return ch.vnodes[i].token >= token
+	index := sort.Search(len(ch.positions), func(i int) bool {
+		return ch.positions[i] >= token
 	})
 	return index
 }
 
-// closest returns the index of the vnode greater than or equal to the token
+// closest returns the index of the vnode greater than or equal to the token, wrapping around to the first vnode
+// It is a binary search so lookups are O(log n) in the number of vnodes
 func (ch *ConsistentHash) closest(token uint64) int {
-	index := sort.Search(len(ch.vnodes), func(i int) bool {
-		return ch.vnodes[i].token >= token
-	})
-	if index == len(ch.vnodes) {
+	index := ch.index(token)
+	if index == len(ch.positions) {
 		index = 0
 	}
 	return index
@@ -4,8 +4,11 @@ package consistentHash
 import (
 	"crypto/rand"
 	"fmt"
+	"math"
 	"runtime"
+	"sort"
 	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/GaryBoone/GoStats/stats"
@@ -34,7 +37,7 @@ func randBytes(size int) []byte {
 func TestVnodeAdd(t *testing.T) {
 	c := New()
 	c.Add("localhost")
-	assert.Equal(t, c.vnodeCount, len(c.vnodes))
+	assert.Equal(t, c.vnodeCount, len(c.positions))
 
 }
 
@@ -123,7 +126,58 @@ func Benchmark_1000VnodeLookup(b *testing.B) {
 	}
 }
 
-// TestinsertVnode verifies that vnodes are correctly inserted in the proper order
+// Benchmark_GetAllocs tests that a steady state Get on a populated ring allocates nothing
+func Benchmark_GetAllocs(b *testing.B) {
+	c := New()
+	addServers(c, 10)
+	key := keys[0]
+	if allocs := testing.AllocsPerRun(100, func() { c.Get(key) }); allocs != 0 {
+		b.Fatalf("Get allocates %.1f times per call", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(keys[i%len(keys)])
+	}
+}
+
+// Benchmark_GetN tests how fast and how much GetN allocates when looking up 3 replicas
+func Benchmark_GetN(b *testing.B) {
+	c := New()
+	addServers(c, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GetN(keys[i%len(keys)], 3)
+	}
+}
+
+// Benchmark_GetNInto is Benchmark_GetN reusing the result slice with GetNInto
+func Benchmark_GetNInto(b *testing.B) {
+	c := New()
+	addServers(c, 10)
+	dst := make([]string, 3)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.GetNInto(keys[i%len(keys)], dst)
+	}
+}
+
+// Benchmark_RemoveServer tests how fast a server with 1000 vnodes is removed from a ring of 10 servers
+func Benchmark_RemoveServer(b *testing.B) {
+	c := New()
+	c.SetVnodeCount(1000)
+	addServers(c, 9)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c.Add("server9")
+		b.StartTimer()
+		c.Remove("server9")
+	}
+}
+
+// TestInsertVnode verifies that vnodes are correctly inserted in the proper order
 func TestInsertVnode(t *testing.T) {
 	ch := New()
 	v1 := vnode{100, "a"}
@@ -134,14 +188,37 @@ func TestInsertVnode(t *testing.T) {
 	ch.insertVnode(v2)
 	ch.insertVnode(v3)
 	ch.insertVnode(v4)
-	assert.Equal(t, 4, len(ch.vnodes))
-	assert.Equal(t, v2, ch.vnodes[0])
-	assert.Equal(t, v1, ch.vnodes[1])
-	assert.Equal(t, v3, ch.vnodes[3])
-	assert.Equal(t, v4, ch.vnodes[2])
+	assert.Equal(t, 4, len(ch.positions))
+	assert.Equal(t, v2, ch.vnodes()[0])
+	assert.Equal(t, v1, ch.vnodes()[1])
+	assert.Equal(t, v3, ch.vnodes()[3])
+	assert.Equal(t, v4, ch.vnodes()[2])
 
 }
 
+// TestAddAtPositions verifies that keys route by the explicit positions, each key going to the first at or after it
+func TestAddAtPositions(t *testing.T) {
+	ch := New()
+	assert.Nil(t, ch.AddAtPositions("low", []uint64{1 << 62, 1 << 63}))
+	assert.Nil(t, ch.AddAtPositions("high", []uint64{3 << 62}))
+	assert.Equal(t, ErrNodeExists, ch.AddAtPositions("low", []uint64{1}))
+	assert.Equal(t, ErrInvalidVnodeCount, ch.AddAtPositions("none", nil))
+	assert.Equal(t, []uint64{1 << 62, 1 << 63}, ch.Positions("low"))
+	assert.Equal(t, 2, ch.VnodeCount("low"))
+	for _, key := range keys {
+		expected := "low"
+		if hash := ch.HashKey(key); hash > 1<<63 && hash <= 3<<62 {
+			expected = "high"
+		}
+		server, err := ch.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, server)
+	}
+	ch.Remove("low")
+	assert.Equal(t, 1, len(ch.positions))
+	assert.Nil(t, ch.Validate())
+}
+
 func TestGet2(t *testing.T) {
 	ch := New()
 	ch.Add("server1")
@@ -151,6 +228,29 @@ func TestGet2(t *testing.T) {
 	assert.True(t, (server1 == "server1" && server2 == "server2") || (server1 == "server2" && server2 == "server1"))
 }
 
+// TestGet2SingleServer verifies that a second server is never made up from another vnode of the first
+func TestGet2SingleServer(t *testing.T) {
+	ch := New()
+	ch.Add("server1")
+	_, _, err := ch.Get2([]byte("testKey"))
+	assert.Equal(t, ErrNotEnoughMembers, err)
+}
+
+// TestGet2Distinct verifies that the two servers differ for every key, even when one server owns most vnodes
+func TestGet2Distinct(t *testing.T) {
+	ch := New()
+	ch.AddWithNodeCount("big", 1000)
+	ch.AddWithNodeCount("small", 20)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		server1, server2, err := ch.Get2(key)
+		assert.Nil(t, err)
+		assert.NotEqual(t, server1, server2)
+		seen[server2] = true
+	}
+	assert.Equal(t, 2, len(seen))
+}
+
 func TestGetN(t *testing.T) {
 	ch := New()
 	ch.Add("server1")
@@ -163,8 +263,1290 @@ func TestGetN(t *testing.T) {
 	assert.Equal(t, 3, len(servers))
 }
 
+// TestGetByHash verifies that looking up a precomputed hash matches looking up the key
+func TestGetByHash(t *testing.T) {
+	ch := New()
+	_, err := ch.GetByHash(0)
+	assert.Equal(t, ErrNoMembers, err)
+	for i := 0; i < 10; i++ {
+		ch.Add("server" + strconv.Itoa(i))
+	}
+	for _, key := range keys {
+		expected, err := ch.Get(key)
+		assert.Nil(t, err)
+		actual, err := ch.GetByHash(ch.hash(key))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+// TestGetOrDefault verifies that the fallback is only returned when the ring cannot map the key
+func TestGetOrDefault(t *testing.T) {
+	ch := New()
+	assert.Equal(t, "fallback", ch.GetOrDefault(keys[0], "fallback"))
+	ch.Add("server1")
+	assert.Equal(t, "server1", ch.GetOrDefault(keys[0], "fallback"))
+	addServers(ch, 5)
+	for _, key := range keys[:100] {
+		expected, _ := ch.Get(key)
+		assert.Equal(t, expected, ch.GetOrDefault(key, "fallback"))
+	}
+	ch.Clear()
+	assert.Equal(t, "", ch.GetOrDefault(keys[0], ""))
+}
+
+// TestOwnerAt verifies that every vnode position is owned by its own server and positions past the last wrap around
+func TestOwnerAt(t *testing.T) {
+	ch := New()
+	_, err := ch.OwnerAt(0)
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 5)
+	ch.Walk(func(position uint64, server string) bool {
+		owner, err := ch.OwnerAt(position)
+		assert.Nil(t, err)
+		assert.Equal(t, server, owner)
+		return true
+	})
+	first, _ := ch.OwnerAt(0)
+	assert.Equal(t, ch.owner(0), first)
+	last, _ := ch.OwnerAt(ch.positions[len(ch.positions)-1] + 1)
+	assert.Equal(t, first, last)
+}
+
+// TestVnodeInfo verifies that each server's vnodes are labelled with replicas 0 to vnodeCount-1 and that each label
+// hashes to the position it is reported at
+func TestVnodeInfo(t *testing.T) {
+	ch := New()
+	_, _, ok := ch.VnodeInfo(0)
+	assert.False(t, ok)
+	addServers(ch, 5)
+	ch.AddWithNodeCount("small", 10)
+	replicas := make(map[string][]int)
+	ch.Walk(func(position uint64, server string) bool {
+		owner, replica, ok := ch.VnodeInfo(position)
+		assert.True(t, ok)
+		assert.Equal(t, server, owner)
+		assert.Equal(t, position, ch.hash(addressToKey(server, replica)))
+		replicas[server] = append(replicas[server], replica)
+		return true
+	})
+	for _, server := range ch.Members() {
+		sort.Ints(replicas[server])
+		for i, replica := range replicas[server] {
+			assert.Equal(t, i, replica)
+		}
+		assert.Equal(t, ch.VnodeCount(server), len(replicas[server]))
+	}
+	_, _, ok = ch.VnodeInfo(ch.positions[0] + 1)
+	assert.False(t, ok)
+
+	ch.Remove("server2")
+	assert.Nil(t, ch.Validate())
+	ch.Walk(func(position uint64, server string) bool {
+		_, replica, _ := ch.VnodeInfo(position)
+		assert.Equal(t, position, ch.hash(addressToKey(server, replica)))
+		return true
+	})
+
+	positions := New()
+	positions.AddAtPositions("a", []uint64{300, 100, 200})
+	_, replica, _ := positions.VnodeInfo(200)
+	assert.Equal(t, 2, replica)
+}
+
+// TestHashKey verifies that routing a key by its hash gives the same member as routing the key
+func TestHashKey(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	for _, key := range keys {
+		expected, _ := ch.Get(key)
+		actual, err := ch.GetByHash(ch.HashKey(key))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	assert.Equal(t, HashMurmur3([]byte("testKey")), ch.HashKey([]byte("testKey")))
+	assert.Equal(t, fnv64a([]byte("testKey")), New(WithHashFunc(fnv64a)).HashKey([]byte("testKey")))
+}
+
+// TestGetUint64 verifies that integer keys are routed by their little-endian encoding
+func TestGetUint64(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	for i := 0; i < 1000; i++ {
+		encoded := []byte{byte(i), byte(i >> 8), 0, 0, 0, 0, 0, 0}
+		expected, _ := ch.Get(encoded)
+		server, err := ch.GetUint64(uint64(i))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, server)
+		again, _ := ch.GetInt(i)
+		assert.Equal(t, server, again)
+	}
+	maxKey := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	expected, _ := ch.Get(maxKey)
+	server, _ := ch.GetInt(-1)
+	assert.Equal(t, expected, server)
+	server, _ = ch.GetUint64(math.MaxUint64)
+	assert.Equal(t, expected, server)
+	_, err := New().GetUint64(1)
+	assert.Equal(t, ErrEmptyRing, err)
+}
+
+func TestMembers(t *testing.T) {
+	ch := New()
+	assert.NotNil(t, ch.Members())
+	assert.Empty(t, ch.Members())
+	ch.Add("server2")
+	ch.AddWithNodeCount("server1", 10)
+	ch.Add("server3")
+	ch.AddWithNodeCount("server3", 10)
+	assert.Equal(t, []string{"server1", "server2", "server3"}, ch.Members())
+	ch.Remove("server2")
+	assert.Equal(t, []string{"server1", "server3"}, ch.Members())
+}
+
+func TestRemove(t *testing.T) {
+	ch := New()
+	assert.Equal(t, ErrNodeNotFound, ch.Remove("server1"))
+	ch.Add("server1")
+	ch.AddWithNodeCount("server2", 10)
+	assert.Nil(t, ch.Remove("server1"))
+	assert.Equal(t, 10, len(ch.positions))
+	assert.Equal(t, ErrNodeNotFound, ch.Remove("server1"))
+	assert.Nil(t, ch.Remove("server2"))
+	assert.Empty(t, ch.vnodes())
+	assert.Equal(t, ErrNodeNotFound, ch.Remove("server2"))
+}
+
+// TestAddTwice verifies that adding a member a second time leaves the ring unchanged
+func TestAddTwice(t *testing.T) {
+	ch := New()
+	assert.False(t, ch.Contains("server1"))
+	assert.Nil(t, ch.Add("server1"))
+	assert.True(t, ch.Contains("server1"))
+	assert.Equal(t, ErrNodeExists, ch.Add("server1"))
+	assert.Equal(t, ErrNodeExists, ch.AddWithNodeCount("server1", 50))
+	assert.Equal(t, ch.vnodeCount, len(ch.positions))
+
+	assert.Nil(t, ch.AddWithNodeCount("server2", 50))
+	assert.Equal(t, ErrNodeExists, ch.Add("server2"))
+	assert.Equal(t, ch.vnodeCount+50, len(ch.positions))
+
+	assert.Equal(t, ErrInvalidVnodeCount, ch.AddWithNodeCount("server3", 0))
+	assert.False(t, ch.Contains("server3"))
+}
+
+func TestContains(t *testing.T) {
+	ch := New()
+	assert.False(t, ch.Contains("server1"))
+	ch.Add("server1")
+	ch.AddWithNodeCount("server2", 10)
+	assert.True(t, ch.Contains("server1"))
+	assert.True(t, ch.Contains("server2"))
+	assert.False(t, ch.Contains("server3"))
+	ch.Remove("server1")
+	assert.False(t, ch.Contains("server1"))
+	assert.True(t, ch.Contains("server2"))
+}
+
+func TestVnodeCount(t *testing.T) {
+	ch := New()
+	assert.Equal(t, 0, ch.VnodeCount("server1"))
+	ch.Add("server1")
+	ch.AddWithNodeCount("server2", 10)
+	ch.AddWithNodeCount("server3", 1000)
+	assert.Equal(t, ch.vnodeCount, ch.VnodeCount("server1"))
+	assert.Equal(t, 10, ch.VnodeCount("server2"))
+	assert.Equal(t, 1000, ch.VnodeCount("server3"))
+	ch.Remove("server3")
+	assert.Equal(t, 0, ch.VnodeCount("server3"))
+	assert.Equal(t, 10, ch.VnodeCount("server2"))
+}
+
+// TestGetNDistinct verifies that GetN never returns the same server twice
+func TestGetNDistinct(t *testing.T) {
+	ch := New()
+	ch.SetVnodeCount(500)
+	addServers(ch, 3)
+	for _, key := range keys {
+		servers, err := ch.GetN(key, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(servers))
+		assert.NotEqual(t, servers[0], servers[1])
+		assert.NotEqual(t, servers[0], servers[2])
+		assert.NotEqual(t, servers[1], servers[2])
+	}
+	_, err := ch.GetN(keys[0], 4)
+	assert.Equal(t, ErrNotEnoughMembers, err)
+	servers, err := ch.GetN(keys[0], 0)
+	assert.Nil(t, err)
+	assert.Empty(t, servers)
+}
+
+// TestSecondaries verifies that the secondaries exclude the primary and follow it in GetN order
+func TestSecondaries(t *testing.T) {
+	ch := New()
+	_, err := ch.Secondaries(keys[0], 1)
+	assert.Equal(t, ErrEmptyRing, err)
+	ch.Add("server0")
+	_, err = ch.Secondaries(keys[0], 1)
+	assert.Equal(t, ErrNotEnoughMembers, err)
+	secondaries, err := ch.Secondaries(keys[0], 0)
+	assert.Nil(t, err)
+	assert.Empty(t, secondaries)
+
+	addServers(ch, 3)
+	for _, key := range keys[:1000] {
+		primary, _ := ch.Get(key)
+		secondaries, err := ch.Secondaries(key, 2)
+		assert.Nil(t, err)
+		assert.NotContains(t, secondaries, primary)
+		all, _ := ch.GetN(key, 3)
+		assert.Equal(t, all[1:], secondaries)
+	}
+	_, err = ch.Secondaries(keys[0], 3)
+	assert.Equal(t, ErrNotEnoughMembers, err)
+}
+
+// TestGetNByDistance verifies that members come closest first, measured clockwise from the key to their nearest vnode
+func TestGetNByDistance(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	distance := func(hash uint64, server string) uint64 {
+		nearest := uint64(math.MaxUint64)
+		for _, position := range ch.Positions(server) {
+			if position-hash < nearest {
+				nearest = position - hash
+			}
+		}
+		return nearest
+	}
+	for _, key := range keys[:1000] {
+		servers, err := ch.GetNByDistance(key, 5)
+		assert.Nil(t, err)
+		hash := ch.HashKey(key)
+		for _, server := range ch.Members() {
+			assert.True(t, distance(hash, servers[0]) <= distance(hash, server))
+		}
+		for i := 1; i < len(servers); i++ {
+			assert.True(t, distance(hash, servers[i-1]) < distance(hash, servers[i]))
+		}
+	}
+
+	tied := New(WithHashFunc(collidingHash), WithVnodeCount(1))
+	tied.Add("b")
+	tied.Add("a")
+	servers, _ := tied.GetNByDistance([]byte("0="), 2)
+	assert.Equal(t, []string{"a", "b"}, servers)
+}
+
+// TestGetUpTo verifies that asking for more members than exist returns all of them in GetN order
+func TestGetUpTo(t *testing.T) {
+	ch := New()
+	assert.Equal(t, []string{}, ch.GetUpTo(keys[0], 3))
+	addServers(ch, 3)
+	for _, key := range keys[:1000] {
+		all, _ := ch.GetN(key, 3)
+		assert.Equal(t, all, ch.GetUpTo(key, 5))
+		assert.Equal(t, all[:2], ch.GetUpTo(key, 2))
+	}
+	assert.Equal(t, []string{}, ch.GetUpTo(keys[0], 0))
+	assert.Equal(t, []string{}, ch.GetUpTo(keys[0], -1))
+}
+
+// TestGetAllocs verifies that lookups allocate nothing once the ring is built, with the default layout, 128bit
+// positions and draining servers
+func TestGetAllocs(t *testing.T) {
+	for _, ch := range []*ConsistentHash{New(), New(WithHash128(nil)), New(WithKetamaCompat())} {
+		addServers(ch, 10)
+		ch.SetDraining("server3", true)
+		key := keys[0]
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { ch.Get(key) }))
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { ch.GetByHash(42) }))
+		assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() { ch.GetIncludingDraining(key) }))
+	}
+}
+
+// TestGetAllOrdered verifies that every member is listed once, starting with the key's owner
+func TestGetAllOrdered(t *testing.T) {
+	ch := New()
+	assert.Equal(t, []string{}, ch.GetAllOrdered(keys[0]))
+	addServers(ch, 7)
+	for _, key := range keys[:1000] {
+		all := ch.GetAllOrdered(key)
+		server, _ := ch.Get(key)
+		assert.Equal(t, server, all[0])
+		sorted := append([]string(nil), all...)
+		sort.Strings(sorted)
+		assert.Equal(t, ch.Members(), sorted)
+		expected, _ := ch.GetN(key, 7)
+		assert.Equal(t, expected, all)
+	}
+
+	ch.SetDraining("server1", true)
+	ch.SetDraining("server2", true)
+	for _, key := range keys[:1000] {
+		all := ch.GetAllOrdered(key)
+		server, _ := ch.Get(key)
+		assert.Equal(t, server, all[0])
+		sorted := append([]string(nil), all...)
+		sort.Strings(sorted)
+		assert.Equal(t, ch.Members(), sorted)
+		ring, _ := ch.GetN(key, 7)
+		rest := make([]string, 0, 6)
+		for _, address := range ring {
+			if address != server {
+				rest = append(rest, address)
+			}
+		}
+		assert.Equal(t, rest, all[1:])
+	}
+
+	single := New()
+	single.Add("server0")
+	assert.Equal(t, []string{"server0"}, single.GetAllOrdered(keys[0]))
+	assert.Equal(t, uint64(1), single.Metrics().Lookups)
+}
+
+// TestGetNInto verifies that GetNInto writes the GetN list, stops at the number of members and allocates nothing
+func TestGetNInto(t *testing.T) {
+	ch := New()
+	dst := make([]string, 3)
+	_, err := ch.GetNInto(keys[0], dst)
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 5)
+	for _, key := range keys[:1000] {
+		expected, _ := ch.GetN(key, 3)
+		count, err := ch.GetNInto(key, dst)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+		assert.Equal(t, expected, dst)
+	}
+	large := make([]string, 8)
+	count, err := ch.GetNInto(keys[0], large)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+	all, _ := ch.GetN(keys[0], 5)
+	assert.Equal(t, all, large[:count])
+	count, err = ch.GetNInto(keys[0], nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ch.GetNInto(keys[0], dst)
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+// TestGetNStability verifies that adding a server only inserts it into the GetN lists of the keys it takes over
+func TestGetNStability(t *testing.T) {
+	ch := New()
+	addServers(ch, 8)
+	before := make([][]string, len(keys))
+	for i, key := range keys {
+		before[i], _ = ch.GetN(key, 3)
+	}
+	ch.Add("new")
+	changed := 0
+	for i, key := range keys {
+		after, err := ch.GetN(key, 3)
+		assert.Nil(t, err)
+		index := -1
+		for j, server := range after {
+			if server == "new" {
+				index = j
+			}
+		}
+		if index == -1 {
+			assert.Equal(t, before[i], after)
+			continue
+		}
+		changed++
+		expected := append(append(append([]string{}, before[i][:index]...), "new"), before[i][index:2]...)
+		assert.Equal(t, expected, after)
+	}
+	// the new server joins about a ninth of the ring at each of the 3 ranks
+	assert.InDelta(t, 3.0/9, float64(changed)/float64(len(keys)), 0.05)
+}
+
+// TestGetNTiebreak verifies that servers colliding on a position are returned in name order, whatever the add order
+func TestGetNTiebreak(t *testing.T) {
+	orders := [][]string{{"c", "a", "b", "d"}, {"b", "d", "c", "a"}, {"a", "b", "c", "d"}}
+	// collidingHash puts the first vnode of every server and this key at position 42
+	key := []byte("0=key")
+	for _, order := range orders {
+		ch := New(WithHashFunc(collidingHash), WithVnodeCount(10))
+		for _, server := range order {
+			ch.Add(server)
+		}
+		for i := 0; i < 10; i++ {
+			servers, err := ch.GetN(key, 3)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"a", "b", "c"}, servers)
+		}
+		servers, _ := ch.GetN(key, 4)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, servers)
+	}
+}
+
+// TestGetNGolden pins GetN lists for a fixed membership so they stay identical across processes and releases
+func TestGetNGolden(t *testing.T) {
+	tests := []struct {
+		key     string
+		servers []string
+	}{
+		{"key0", []string{"server3", "server4", "server2"}},
+		{"key1", []string{"server3", "server1", "server2"}},
+		{"key2", []string{"server2", "server4", "server1"}},
+		{"key3", []string{"server1", "server2", "server3"}},
+		{"key4", []string{"server2", "server4", "server3"}},
+		{"key5", []string{"server4", "server3", "server1"}},
+		{"key6", []string{"server3", "server4", "server2"}},
+		{"key7", []string{"server3", "server0", "server1"}},
+	}
+	forward := New()
+	backward := New()
+	for i := 0; i < 5; i++ {
+		forward.Add("server" + strconv.Itoa(i))
+		backward.Add("server" + strconv.Itoa(4-i))
+	}
+	for _, ch := range []*ConsistentHash{forward, backward} {
+		for _, test := range tests {
+			servers, err := ch.GetN([]byte(test.key), 3)
+			assert.Nil(t, err)
+			assert.Equal(t, test.servers, servers, test.key)
+		}
+	}
+}
+
+// TestEqual verifies that identically built rings are equal and that any change in membership or counts is detected
+func TestEqual(t *testing.T) {
+	c1 := New()
+	c2 := New()
+	assert.True(t, c1.Equal(c2))
+	addServers(c1, 5)
+	for i := 4; i >= 0; i-- {
+		c2.Add("server" + strconv.Itoa(i))
+	}
+	assert.True(t, c1.Equal(c2))
+	assert.True(t, c2.Equal(c1))
+	assert.True(t, c1.Equal(c1))
+	assert.True(t, c1.Equal(c1.Clone()))
+
+	c2.SetNodeVnodeCount("server2", 100)
+	assert.False(t, c1.Equal(c2))
+	c2.SetNodeVnodeCount("server2", 200)
+	assert.True(t, c1.Equal(c2))
+	c2.Remove("server2")
+	c2.AddWithNodeCount("server2", 199)
+	assert.False(t, c1.Equal(c2))
+
+	c3 := New(WithSeed(1))
+	addServers(c3, 5)
+	assert.False(t, c1.Equal(c3))
+	c4 := New(WithHash128(nil))
+	c5 := New(WithHash128(nil))
+	addServers(c4, 3)
+	addServers(c5, 3)
+	assert.True(t, c4.Equal(c5))
+	c5.lows[0]++
+	assert.False(t, c4.Equal(c5))
+}
+
+// TestClone verifies that mutating a clone leaves the original unchanged
+// TestRebuild verifies that a rebuilt ring places keys like a ring built from scratch with the new scheme
+func TestRebuild(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	ch.AddWithNodeCount("small", 20)
+	ch.AddWithZone("zoned", "zone1")
+	fnv := ch.Rebuild(WithHashFunc(HashFNV1a))
+	expected := New(WithHashFunc(HashFNV1a))
+	addServers(expected, 5)
+	expected.AddWithNodeCount("small", 20)
+	expected.Add("zoned")
+	assert.True(t, expected.Equal(fnv))
+	assert.Equal(t, "zone1", fnv.Zone("zoned"))
+	assert.Equal(t, 20, fnv.VnodeCount("small"))
+
+	key := []byte("testKey")
+	oldServer, _ := ch.Get(key)
+	newServer, _ := fnv.Get(key)
+	expectedServer, _ := expected.Get(key)
+	assert.Equal(t, expectedServer, newServer)
+	if oldServer != newServer {
+		assert.Equal(t, [2]string{oldServer, newServer}, MigrationPlan(ch, fnv, [][]byte{key})[string(key)])
+	}
+	plan := MigrationPlan(ch, fnv, keys)
+	// independent schemes agree on about one key in seven
+	assert.InDelta(t, 6.0/7, float64(len(plan))/float64(len(keys)), 0.05)
+	assert.True(t, ch.Rebuild().Equal(ch))
+}
+
+// TestNewFromWeights verifies that maps with the same entries built in different orders give equal rings
+func TestNewFromWeights(t *testing.T) {
+	forward := make(map[string]int)
+	backward := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		forward["server"+strconv.Itoa(i)] = 10 * (i + 1)
+		backward["server"+strconv.Itoa(19-i)] = 10 * (20 - i)
+	}
+	c1 := NewFromWeights(forward, WithHashFunc(collidingHash))
+	c2 := NewFromWeights(backward, WithHashFunc(collidingHash))
+	assert.True(t, c1.Equal(c2))
+	assert.Equal(t, c1.vnodes(), c2.vnodes())
+	assert.Equal(t, 200, c1.VnodeCount("server19"))
+
+	expected := New()
+	expected.AddWithNodeCount("a", 10)
+	expected.AddWithNodeCount("b", 300)
+	ch := NewFromWeights(map[string]int{"b": 300, "a": 10, "skipped": 0})
+	assert.True(t, expected.Equal(ch))
+	assert.Equal(t, 0, NewFromWeights(nil).Size())
+}
+
+func TestClone(t *testing.T) {
+	ch := New(WithHashFunc(fnv64a))
+	ch.SetVnodeCount(50)
+	addServers(ch, 5)
+	ch.AddWithZone("zoned", "zone1")
+	clone := ch.Clone()
+	assert.Equal(t, ch.vnodes(), clone.vnodes())
+	assert.Equal(t, ch.Members(), clone.Members())
+	assert.Equal(t, "zone1", clone.Zone("zoned"))
+
+	original := append(vnodes{}, ch.vnodes()...)
+	clone.Remove("server0")
+	clone.Remove("zoned")
+	clone.AddWithNodeCount("server9", 10)
+	assert.Equal(t, original, ch.vnodes())
+	assert.True(t, ch.Contains("server0"))
+	assert.False(t, ch.Contains("server9"))
+	assert.Equal(t, 50, ch.VnodeCount("server0"))
+	assert.Equal(t, "zone1", ch.Zone("zoned"))
+	clone = ch.Clone()
+	for _, key := range keys {
+		expected, _ := ch.Get(key)
+		actual, _ := clone.Get(key)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+// TestStringKeys verifies that the string helpers match the []byte lookups
+func TestStringKeys(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	for _, key := range keys {
+		expected, _ := ch.Get(key)
+		actual, err := ch.GetString(string(key))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+
+		expected1, expected2, _ := ch.Get2(key)
+		actual1, actual2, err := ch.Get2String(string(key))
+		assert.Nil(t, err)
+		assert.Equal(t, expected1, actual1)
+		assert.Equal(t, expected2, actual2)
+
+		expectedN, _ := ch.GetN(key, 3)
+		actualN, err := ch.GetNString(string(key), 3)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedN, actualN)
+	}
+}
+
+func TestSize(t *testing.T) {
+	ch := New()
+	assert.Equal(t, 0, ch.Size())
+	assert.True(t, ch.IsEmpty())
+	_, err := ch.Get([]byte("testKey"))
+	assert.Equal(t, ErrEmptyRing, err)
+
+	ch.Add("server1")
+	assert.Equal(t, 1, ch.Size())
+	assert.False(t, ch.IsEmpty())
+	server, err := ch.Get([]byte("testKey"))
+	assert.Nil(t, err)
+	assert.Equal(t, "server1", server)
+
+	ch.AddWithNodeCount("server2", 10)
+	ch.Add("server3")
+	assert.Equal(t, 3, ch.Size())
+	ch.Remove("server1")
+	assert.Equal(t, 2, ch.Size())
+}
+
+// TestNumVnodes verifies that the vnode total follows the members and their vnode counts
+func TestNumVnodes(t *testing.T) {
+	ch := New(WithVnodeCount(50))
+	assert.Equal(t, 0, ch.NumVnodes())
+	addServers(ch, 6)
+	assert.Equal(t, ch.Size()*50, ch.NumVnodes())
+	ch.AddWithNodeCount("small", 10)
+	assert.Equal(t, 6*50+10, ch.NumVnodes())
+	ch.Remove("server0")
+	assert.Equal(t, 5*50+10, ch.NumVnodes())
+}
+
+// TestVnodePositions verifies that the positions are a sorted copy with one entry per vnode
+func TestVnodePositions(t *testing.T) {
+	ch := New()
+	assert.Empty(t, ch.VnodePositions())
+	addServers(ch, 6)
+	ch.AddWithNodeCount("small", 10)
+	positions := ch.VnodePositions()
+	assert.Equal(t, ch.NumVnodes(), len(positions))
+	assert.True(t, sort.SliceIsSorted(positions, func(i, j int) bool { return positions[i] < positions[j] }))
+	for _, position := range ch.Positions("small") {
+		owner, _ := ch.OwnerAt(position)
+		assert.Equal(t, "small", owner)
+	}
+	positions[0] = math.MaxUint64
+	assert.NotEqual(t, uint64(math.MaxUint64), ch.VnodePositions()[0])
+}
+
+// TestEmptyRing verifies that every lookup on an empty ring returns ErrEmptyRing
+func TestEmptyRing(t *testing.T) {
+	ch := New()
+	key := []byte("testKey")
+	_, err := ch.Get(key)
+	assert.Equal(t, ErrEmptyRing, err)
+	_, _, err = ch.Get2(key)
+	assert.Equal(t, ErrEmptyRing, err)
+	_, err = ch.GetN(key, 1)
+	assert.Equal(t, ErrEmptyRing, err)
+	_, err = ch.GetN(key, 0)
+	assert.Equal(t, ErrEmptyRing, err)
+	_, err = ch.GetNDistinctZones(key, 1)
+	assert.Equal(t, ErrEmptyRing, err)
+
+	ch.Add("server1")
+	ch.Remove("server1")
+	_, err = ch.Get(key)
+	assert.Equal(t, ErrEmptyRing, err)
+	_, _, err = ch.Get2(key)
+	assert.Equal(t, ErrEmptyRing, err)
+}
+
+func TestClear(t *testing.T) {
+	ch := New(WithHashFunc(fnv64a))
+	ch.SetVnodeCount(50)
+	addServers(ch, 5)
+	ch.AddWithZone("zoned", "zone1")
+	ch.Clear()
+	assert.Equal(t, 0, ch.Size())
+	assert.Empty(t, ch.vnodes())
+	assert.Equal(t, "", ch.Zone("zoned"))
+	_, err := ch.Get([]byte("testKey"))
+	assert.Equal(t, ErrEmptyRing, err)
+
+	assert.Nil(t, ch.Add("server0"))
+	assert.Equal(t, 50, ch.VnodeCount("server0"))
+	expected := New(WithHashFunc(fnv64a))
+	expected.SetVnodeCount(50)
+	expected.Add("server0")
+	assert.Equal(t, expected.vnodes(), ch.vnodes())
+}
+
+func TestGetNFunc(t *testing.T) {
+	ch := New()
+	addServers(ch, 3)
+	replicas := func(key []byte) int {
+		if key[0] < 128 {
+			return 3
+		}
+		return 1
+	}
+	for _, key := range keys {
+		servers, err := ch.GetNFunc(key, replicas)
+		assert.Nil(t, err)
+		assert.Equal(t, replicas(key), len(servers))
+		expected, _ := ch.GetN(key, replicas(key))
+		assert.Equal(t, expected, servers)
+	}
+	_, err := ch.GetNFunc(keys[0], func([]byte) int { return 4 })
+	assert.Equal(t, ErrNotEnoughMembers, err)
+}
+
+func TestRebalance(t *testing.T) {
+	ch := New(WithVnodeCount(100))
+	addServers(ch, 5)
+	ch.AddWithNodeCount("small", 10)
+	ch.AddWithZone("zoned", "zone1")
+	assert.Equal(t, ErrInvalidVnodeCount, ch.Rebalance(0))
+	assert.Nil(t, ch.Rebalance(500))
+	for _, server := range ch.Members() {
+		assert.Equal(t, 500, ch.VnodeCount(server))
+	}
+	assert.Equal(t, 7*500, len(ch.positions))
+	assert.Equal(t, "zone1", ch.Zone("zoned"))
+	ch.Add("server9")
+	assert.Equal(t, 500, ch.VnodeCount("server9"))
+
+	expected := New(WithVnodeCount(500))
+	addServers(expected, 5)
+	expected.Add("small")
+	expected.Add("zoned")
+	expected.Add("server9")
+	assert.Equal(t, expected.vnodes(), ch.vnodes())
+}
+
+// TestAddWithWeight verifies that a node with twice the weight receives about twice the keys
+func TestAddWithWeight(t *testing.T) {
+	ch := New()
+	assert.Nil(t, ch.AddWithWeight("light", 1.0))
+	assert.Nil(t, ch.AddWithWeight("heavy", 2.0))
+	assert.Nil(t, ch.AddWithWeight("tiny", 0.01))
+	assert.Equal(t, ch.vnodeCount, ch.VnodeCount("light"))
+	assert.Equal(t, 2*ch.vnodeCount, ch.VnodeCount("heavy"))
+	assert.Equal(t, 2, ch.VnodeCount("tiny"))
+	assert.Equal(t, ErrInvalidWeight, ch.AddWithWeight("zero", 0))
+	assert.Equal(t, ErrInvalidWeight, ch.AddWithWeight("negative", -1))
+	assert.Equal(t, ErrInvalidWeight, ch.AddWithWeight("nan", math.NaN()))
+	assert.Equal(t, ErrNodeExists, ch.AddWithWeight("light", 1))
+	ch.Remove("tiny")
+
+	counts := make(map[string]int)
+	for i := 0; i < 100000; i++ {
+		server, _ := ch.Get(randBytes(10))
+		counts[server]++
+	}
+	ratio := float64(counts["heavy"]) / float64(counts["light"])
+	t.Logf("heavy=%d light=%d ratio=%.2f", counts["heavy"], counts["light"], ratio)
+	assert.InDelta(t, 2.0, ratio, 0.4)
+}
+
+// TestInsertionOrder verifies that every order of adding the same servers builds an equal ring, even when their
+// vnodes collide
+func TestInsertionOrder(t *testing.T) {
+	permutations := [][]string{
+		{"s1", "s2", "s3"}, {"s1", "s3", "s2"}, {"s2", "s1", "s3"},
+		{"s2", "s3", "s1"}, {"s3", "s1", "s2"}, {"s3", "s2", "s1"},
+	}
+	for _, opts := range [][]Option{
+		{WithHashFunc(collidingHash), WithVnodeCount(20)},
+		{WithHash128(nil), WithVnodeCount(20)},
+		nil,
+	} {
+		var rings []*ConsistentHash
+		for _, permutation := range permutations {
+			ch := New(opts...)
+			for _, server := range permutation {
+				ch.Add(server)
+			}
+			rings = append(rings, ch)
+		}
+		for _, ch := range rings[1:] {
+			assert.True(t, rings[0].Equal(ch))
+			assert.Equal(t, rings[0].vnodes(), ch.vnodes())
+		}
+	}
+}
+
+// collidingHash places replica 0 of every server on the same token
+func collidingHash(data []byte) uint64 {
+	if data[0] == '0' && data[1] == '=' {
+		return 42
+	}
+	return HashMurmur3(data)
+}
+
+// TestInsertVnodeCollision verifies that colliding vnodes are all kept in a deterministic order
+func TestInsertVnodeCollision(t *testing.T) {
+	c1 := New(WithHashFunc(collidingHash), WithVnodeCount(10))
+	c1.Add("b")
+	c1.Add("a")
+	c1.Add("c")
+	c2 := New(WithHashFunc(collidingHash), WithVnodeCount(10))
+	c2.Add("c")
+	c2.Add("a")
+	c2.Add("b")
+	assert.Equal(t, c1.vnodes(), c2.vnodes())
+	assert.Equal(t, 30, len(c1.positions))
+	for _, server := range []string{"a", "b", "c"} {
+		assert.Equal(t, 10, c1.VnodeCount(server))
+	}
+	index := c1.closest(42)
+	assert.Equal(t, vnode{42, "a"}, c1.vnodes()[index])
+	assert.Equal(t, vnode{42, "b"}, c1.vnodes()[index+1])
+	assert.Equal(t, vnode{42, "c"}, c1.vnodes()[index+2])
+
+	reachable := make(map[string]bool)
+	for _, key := range keys {
+		server, _ := c1.Get(key)
+		reachable[server] = true
+	}
+	assert.Equal(t, 3, len(reachable))
+
+	c1.Remove("a")
+	assert.Equal(t, vnode{42, "b"}, c1.vnodes()[c1.closest(42)])
+	assert.Equal(t, 20, len(c1.positions))
+}
+
+// linearClosest is a brute force version of closest
+func linearClosest(ch *ConsistentHash, token uint64) int {
+	for i, position := range ch.positions {
+		if position >= token {
+			return i
+		}
+	}
+	return 0
+}
+
+// TestClosestMatchesLinearScan verifies the binary search against a linear scan
+func TestClosestMatchesLinearScan(t *testing.T) {
+	ch := New(WithVnodeCount(1000))
+	addServers(ch, 10)
+	for _, key := range keys {
+		token := ch.hash(key)
+		assert.Equal(t, linearClosest(ch, token), ch.closest(token))
+	}
+	for _, token := range []uint64{0, 1, ch.positions[0], ch.positions[len(ch.positions)-1], math.MaxUint64} {
+		assert.Equal(t, linearClosest(ch, token), ch.closest(token))
+	}
+}
+
+func TestGetBatch(t *testing.T) {
+	ch := New()
+	_, err := ch.GetBatch(keys)
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 10)
+	servers, err := ch.GetBatch(keys)
+	assert.Nil(t, err)
+	assert.Equal(t, len(keys), len(servers))
+	for i, key := range keys {
+		server, _ := ch.Get(key)
+		assert.Equal(t, server, servers[i])
+	}
+	servers, err = ch.GetBatch(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, servers)
+}
+
+// TestGetDetailed verifies that a key lands on the first vnode at or after its hash, wrapping around to the first vnode
+func TestGetDetailed(t *testing.T) {
+	ch := New(WithHashFunc(func(data []byte) uint64 {
+		if string(data) == "wrap" {
+			return math.MaxUint64
+		}
+		return HashMurmur3(data)
+	}))
+	_, _, _, err := ch.GetDetailed([]byte("key"))
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 5)
+	for _, key := range append(keys[:1000:1000], []byte("wrap")) {
+		server, vnodePos, keyHash, err := ch.GetDetailed(key)
+		assert.Nil(t, err)
+		assert.Equal(t, ch.hash(key), keyHash)
+		expected, _ := ch.Get(key)
+		assert.Equal(t, expected, server)
+		index := linearClosest(ch, keyHash)
+		assert.Equal(t, ch.positions[index], vnodePos)
+		if keyHash > ch.positions[len(ch.positions)-1] {
+			assert.Equal(t, ch.positions[0], vnodePos)
+		} else {
+			assert.True(t, vnodePos >= keyHash)
+		}
+	}
+
+	ch.SetDraining("server1", true)
+	for _, key := range keys[:1000] {
+		server, vnodePos, _, err := ch.GetDetailed(key)
+		assert.Nil(t, err)
+		expected, _ := ch.Get(key)
+		assert.Equal(t, expected, server)
+		owner, _ := ch.OwnerAt(vnodePos)
+		assert.Equal(t, server, owner)
+	}
+}
+
+// TestKeysOwnedBy verifies that every key is owned by exactly one server
+func TestKeysOwnedBy(t *testing.T) {
+	ch := New()
+	assert.Empty(t, ch.KeysOwnedBy("server0", keys))
+	addServers(ch, 5)
+	seen := make(map[string]int)
+	for _, server := range ch.Members() {
+		owned := ch.KeysOwnedBy(server, keys)
+		for _, key := range owned {
+			owner, _ := ch.Get(key)
+			assert.Equal(t, server, owner)
+			seen[string(key)]++
+		}
+	}
+	assert.Equal(t, len(keys), len(seen))
+	for _, count := range seen {
+		assert.Equal(t, 1, count)
+	}
+	assert.Empty(t, ch.KeysOwnedBy("missing", keys))
+
+	ch.SetDraining("server1", true)
+	assert.Empty(t, ch.KeysOwnedBy("server1", keys))
+	for _, key := range ch.KeysOwnedBy("server2", keys) {
+		owner, _ := ch.Get(key)
+		assert.Equal(t, "server2", owner)
+	}
+}
+
+// Benchmark_DefaultBatchLookup tests how fast lookups are when done in batches of 100 keys
+func Benchmark_DefaultBatchLookup(b *testing.B) {
+	c := New()
+	addServers(c, 10)
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 100 {
+		start := i % (len(keys) - 100)
+		c.GetBatch(keys[start : start+100])
+	}
+}
+
+func TestWalk(t *testing.T) {
+	ch := New()
+	ch.Walk(func(uint64, string) bool {
+		t.Fatal("walked an empty ring")
+		return false
+	})
+	addServers(ch, 5)
+	var positions []uint64
+	servers := make(map[string]int)
+	ch.Walk(func(position uint64, server string) bool {
+		positions = append(positions, position)
+		servers[server]++
+		return true
+	})
+	assert.Equal(t, 5*ch.vnodeCount, len(positions))
+	for i := 1; i < len(positions); i++ {
+		assert.True(t, positions[i-1] <= positions[i])
+	}
+	for _, count := range servers {
+		assert.Equal(t, ch.vnodeCount, count)
+	}
+
+	visited := 0
+	ch.Walk(func(uint64, string) bool {
+		visited++
+		return visited < 10
+	})
+	assert.Equal(t, 10, visited)
+}
+
+func TestGetExcluding(t *testing.T) {
+	ch := New()
+	_, err := ch.GetExcluding(keys[0], nil)
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 3)
+	excluded := map[string]bool{"server1": true}
+	for _, key := range keys {
+		servers, _ := ch.GetN(key, 3)
+		server, err := ch.GetExcluding(key, excluded)
+		assert.Nil(t, err)
+		if servers[0] == "server1" {
+			assert.Equal(t, servers[1], server)
+		} else {
+			assert.Equal(t, servers[0], server)
+		}
+		server, _ = ch.GetExcluding(key, nil)
+		assert.Equal(t, servers[0], server)
+	}
+	_, err = ch.GetExcluding(keys[0], map[string]bool{"server0": true, "server1": true, "server2": true})
+	assert.Equal(t, ErrNoAvailableMembers, err)
+
+	// a member may be named "", which must not be mistaken for no member
+	unnamed := New()
+	unnamed.Add("")
+	unnamed.Add("a")
+	server, err := unnamed.GetExcluding(keys[0], map[string]bool{"a": true})
+	assert.Nil(t, err)
+	assert.Equal(t, "", server)
+}
+
+// TestGetWithFallbacks verifies that the first fallback takes over when the primary is removed
+func TestGetWithFallbacks(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	key := []byte("testKey")
+	primary, fallbacks, err := ch.GetWithFallbacks(key, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(fallbacks))
+	assert.NotContains(t, fallbacks, primary)
+	assert.NotEqual(t, fallbacks[0], fallbacks[1])
+
+	ch.Remove(primary)
+	newPrimary, newFallbacks, err := ch.GetWithFallbacks(key, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, fallbacks[0], newPrimary)
+	assert.Equal(t, fallbacks[1], newFallbacks[0])
+
+	_, fallbacks, err = ch.GetWithFallbacks(key, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, fallbacks)
+	_, _, err = ch.GetWithFallbacks(key, 4)
+	assert.Equal(t, ErrNotEnoughMembers, err)
+}
+
+// TestGetPlacement verifies that the primary and replicas are distinct members in ring order
+func TestGetPlacement(t *testing.T) {
+	ch := New()
+	_, err := ch.GetPlacement([]byte("testKey"), 0)
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 3)
+	for _, key := range keys[:1000] {
+		placement, err := ch.GetPlacement(key, 0)
+		assert.Nil(t, err)
+		primary, _ := ch.Get(key)
+		assert.Equal(t, Placement{Primary: primary, Replicas: []string{}}, placement)
+
+		placement, err = ch.GetPlacement(key, 2)
+		assert.Nil(t, err)
+		servers, _ := ch.GetN(key, 3)
+		assert.Equal(t, servers[0], placement.Primary)
+		assert.Equal(t, servers[1:], placement.Replicas)
+		assert.NotContains(t, placement.Replicas, placement.Primary)
+		assert.NotEqual(t, placement.Replicas[0], placement.Replicas[1])
+	}
+	placement, err := ch.GetPlacement([]byte("testKey"), -1)
+	assert.Nil(t, err)
+	assert.Empty(t, placement.Replicas)
+	_, err = ch.GetPlacement([]byte("testKey"), 3)
+	assert.Equal(t, ErrNotEnoughMembers, err)
+}
+
+// assertPositionsConsistent checks that Positions agrees with the ring for every member and that no vnode is unaccounted for
+func assertPositionsConsistent(t *testing.T, ch *ConsistentHash) {
+	total := 0
+	for _, member := range ch.Members() {
+		var expected []uint64
+		for i := range ch.owners {
+			if ch.owner(i) == member {
+				expected = append(expected, ch.positions[i])
+			}
+		}
+		assert.Equal(t, expected, ch.Positions(member))
+		total += len(expected)
+	}
+	assert.Equal(t, len(ch.positions), total)
+	assert.Equal(t, len(ch.owned), ch.Size())
+	assert.Equal(t, len(ch.ids), ch.Size())
+}
+
+// TestPositions verifies that the tracked positions stay consistent with the ring through adds and removes
+func TestPositions(t *testing.T) {
+	c := New()
+	c.SetVnodeCount(50)
+	assert.Nil(t, c.Positions("server0"))
+	addServers(c, 5)
+	assertPositionsConsistent(t, c)
+	assert.Equal(t, 50, len(c.Positions("server2")))
+
+	c.Remove("server2")
+	assertPositionsConsistent(t, c)
+	assert.Nil(t, c.Positions("server2"))
+	assert.Equal(t, 4*50, len(c.positions))
+
+	c.AddWithNodeCount("server2", 10)
+	assertPositionsConsistent(t, c)
+	assert.Equal(t, 10, len(c.Positions("server2")))
+	// the interned name of the removed server is reused
+	assert.Equal(t, 5, len(c.names))
+
+	positions := c.Positions("server0")
+	positions[0] = 0
+	assert.NotEqual(t, positions, c.Positions("server0"))
+}
+
+// TestRemoveLeavesNoStrayVnodes verifies that Remove clears every vnode even when tokens collide
+func TestRemoveLeavesNoStrayVnodes(t *testing.T) {
+	// the first two vnodes of server0 are identical and collide with the first vnode of every other server
+	c := New(WithHashFunc(func(data []byte) uint64 {
+		if string(data) == "1=server0" || data[0] == '0' && data[1] == '=' {
+			return 42
+		}
+		return HashMurmur3(data)
+	}))
+	c.SetVnodeCount(20)
+	addServers(c, 4)
+	assertPositionsConsistent(t, c)
+
+	for _, server := range []string{"server0", "server3", "server1", "server2"} {
+		c.Remove(server)
+		assertPositionsConsistent(t, c)
+		for i := range c.owners {
+			assert.NotEqual(t, server, c.owner(i))
+		}
+	}
+	assert.Equal(t, 0, len(c.positions))
+	assert.Equal(t, 0, len(c.owners))
+}
+
+// TestRename verifies that a renamed server keeps its positions so every key maps to the same vnode
+func TestRename(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	ch.AddWithZone("zoned", "zone1")
+	before := make([]uint64, 1000)
+	owners := make([]string, 1000)
+	for i, key := range keys[:1000] {
+		owners[i], before[i], _, _ = ch.GetDetailed(key)
+	}
+	positions := ch.Positions("server2")
+	var addedServers, removedServers []string
+	ch.OnAdd(func(server string) { addedServers = append(addedServers, server) })
+	ch.OnRemove(func(server string) { removedServers = append(removedServers, server) })
+
+	assert.Nil(t, ch.Rename("server2", "renamed"))
+	assert.Nil(t, ch.Rename("zoned", "moved"))
+	assert.Equal(t, []string{"renamed", "moved"}, addedServers)
+	assert.Equal(t, []string{"server2", "zoned"}, removedServers)
+	assert.False(t, ch.Contains("server2"))
+	assert.Equal(t, positions, ch.Positions("renamed"))
+	assert.Equal(t, "zone1", ch.Zone("moved"))
+	for i, key := range keys[:1000] {
+		server, vnodePos, _, _ := ch.GetDetailed(key)
+		assert.Equal(t, before[i], vnodePos)
+		switch owners[i] {
+		case "server2":
+			assert.Equal(t, "renamed", server)
+		case "zoned":
+			assert.Equal(t, "moved", server)
+		default:
+			assert.Equal(t, owners[i], server)
+		}
+	}
+
+	assert.Equal(t, ErrNodeNotFound, ch.Rename("server2", "other"))
+	assert.Equal(t, ErrNodeExists, ch.Rename("renamed", "server1"))
+	assert.Nil(t, ch.Remove("renamed"))
+	assertPositionsConsistent(t, ch)
+}
+
+// TestRenameCollision verifies that colliding vnodes stay ordered by address after a rename
+func TestRenameCollision(t *testing.T) {
+	c1 := New(WithHashFunc(collidingHash), WithVnodeCount(10))
+	c1.Add("a")
+	c1.Add("b")
+	c1.Add("c")
+	assert.Nil(t, c1.Rename("a", "d"))
+	index := c1.closest(42)
+	assert.Equal(t, vnode{42, "b"}, c1.vnodes()[index])
+	assert.Equal(t, vnode{42, "c"}, c1.vnodes()[index+1])
+	assert.Equal(t, vnode{42, "d"}, c1.vnodes()[index+2])
+	assertPositionsConsistent(t, c1)
+}
+
+// TestReplace verifies that new takes the vnode count of old with its own positions and that a concurrent reader
+// always sees exactly one of them
+func TestReplace(t *testing.T) {
+	ch := New()
+	addServers(ch, 3)
+	ch.AddWithNodeCount("old", 50)
+	assert.Equal(t, ErrNodeNotFound, ch.Replace("missing", "new"))
+	assert.Equal(t, ErrNodeExists, ch.Replace("old", "server0"))
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			members := ch.Members()
+			if len(members) != 4 || (members[0] != "old" && members[0] != "new") {
+				t.Errorf("observed members %v", members)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, ch.Replace("old", "new"))
+		assert.Nil(t, ch.Replace("new", "old"))
+	}
+	close(stop)
+	wg.Wait()
+
+	assert.Nil(t, ch.Replace("old", "new"))
+	assert.False(t, ch.Contains("old"))
+	assert.Equal(t, 50, ch.VnodeCount("new"))
+	expected := New()
+	addServers(expected, 3)
+	expected.AddWithNodeCount("new", 50)
+	assert.True(t, expected.Equal(ch))
+}
+
+// keyShare returns the fraction of keys that map to server
+func keyShare(ch *ConsistentHash, server string) float64 {
+	owned := 0
+	for _, key := range keys {
+		if owner, _ := ch.Get(key); owner == server {
+			owned++
+		}
+	}
+	return float64(owned) / float64(len(keys))
+}
+
+// TestSetNodeVnodeCount verifies that shrinking and growing a server changes its key share proportionally
+func TestSetNodeVnodeCount(t *testing.T) {
+	ch := New()
+	addServers(ch, 4)
+	full := keyShare(ch, "server0")
+	positions := ch.Positions("server0")
+
+	assert.Nil(t, ch.SetNodeVnodeCount("server0", 100))
+	assert.Equal(t, 100, ch.VnodeCount("server0"))
+	assert.Equal(t, positions[:100], ch.Positions("server0"))
+	assertPositionsConsistent(t, ch)
+	// half the vnodes against three full servers is 1/7 instead of 1/4 of the ring
+	half := keyShare(ch, "server0")
+	assert.InDelta(t, full*4/7, half, 0.05)
+
+	assert.Nil(t, ch.SetNodeVnodeCount("server0", 600))
+	assert.Equal(t, 600, ch.VnodeCount("server0"))
+	assertPositionsConsistent(t, ch)
+	for _, position := range positions[:100] {
+		assert.Contains(t, ch.Positions("server0"), position)
+	}
+	assert.InDelta(t, 0.5, keyShare(ch, "server0"), 0.05)
+
+	assert.Equal(t, ErrNodeNotFound, ch.SetNodeVnodeCount("missing", 10))
+	assert.Equal(t, ErrInvalidVnodeCount, ch.SetNodeVnodeCount("server0", 0))
+	assert.Nil(t, ch.Remove("server0"))
+	assertPositionsConsistent(t, ch)
+}
+
+// TestReweight verifies that Reweight gives the vnodes of a fresh add and moves fewer keys than removing the
+// server and adding it back with the new count
+func TestReweight(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	assert.Equal(t, ErrNodeNotFound, ch.Reweight("server10", 100))
+	assert.Equal(t, ErrInvalidVnodeCount, ch.Reweight("server0", 0))
+	for _, newCount := range []int{300, 50} {
+		before := ch.Clone()
+		removed := ch.Clone()
+		removed.Remove("server0")
+		readded := removed.Clone()
+		readded.AddWithNodeCount("server0", newCount)
+		assert.Nil(t, ch.Reweight("server0", newCount))
+		assert.True(t, readded.Equal(ch))
+		reweightMoves := len(MigrationPlan(before, ch, keys))
+		readdMoves := len(MigrationPlan(before, removed, keys)) + len(MigrationPlan(removed, readded, keys))
+		assert.True(t, reweightMoves < readdMoves, "reweight moved %d keys, remove and add %d", reweightMoves, readdMoves)
+		assert.Equal(t, newCount, ch.VnodeCount("server0"))
+		assertPositionsConsistent(t, ch)
+	}
+
+	// reweighting after SetNodeVnodeCount restores the usual replicas
+	ch.SetNodeVnodeCount("server1", 20)
+	ch.Reweight("server1", 200)
+	fresh := New()
+	addServers(fresh, 10)
+	fresh.Reweight("server0", 50)
+	assert.True(t, fresh.Equal(ch))
+}
+
 // TestRemoveVnode verifies that vnodes are correctly removed
-func TestremoveVnode(t *testing.T) {
+func TestRemoveVnode(t *testing.T) {
 	ch := New()
 	v1 := vnode{100, "a"}
 	v2 := vnode{50, "b"}
@@ -174,54 +1556,38 @@ func TestremoveVnode(t *testing.T) {
 	ch.insertVnode(v2)
 	ch.insertVnode(v3)
 	ch.insertVnode(v4)
-	ch.removeVnode(50)
-	assert.Equal(t, 3, len(ch.vnodes))
-	ch.removeVnode(1001)
-	ch.removeVnode(100)
-	ch.removeVnode(1000)
-	assert.Empty(t, ch.vnodes)
+	ch.removeVnode(v2)
+	assert.Equal(t, 3, len(ch.positions))
+	ch.removeVnode(v3)
+	ch.removeVnode(v1)
+	ch.removeVnode(v4)
+	assert.Empty(t, ch.vnodes())
 
 }
 
-func Examplebasic() {
-	// Output: key=A server=server3
-	//key=B server=server3
-	//key=C server=server1
-	//key=D server=server3
-	//key=E server=server2
-	//key=F server=server2
-	//key=G server=server1
+func Example_basic() {
 	ch := New()
 	ch.Add("server1")
 	ch.Add("server2")
 	ch.Add("server3")
 	keys := []string{"A", "B", "C", "D", "E", "F", "G"}
 	for _, key := range keys {
-		server, err := ch.Get([]byte(key))
+		server, err := ch.Get(KeyFromString(key))
 		if err != nil {
 			panic(err)
 		}
 		fmt.Printf("key=%s server=%s\n", key, server)
 	}
+	// Output: key=A server=server1
+	// key=B server=server2
+	// key=C server=server2
+	// key=D server=server1
+	// key=E server=server3
+	// key=F server=server2
+	// key=G server=server1
 }
 
-func Exampleremove() {
-	//Output: 3 servers
-	//key=A server=server3
-	//key=B server=server3
-	//key=C server=server1
-	//key=D server=server3
-	//key=E server=server2
-	//key=F server=server2
-	//key=G server=server1
-	//Removing server3
-	//key=A server=server1
-	//key=B server=server2
-	//key=C server=server1
-	//key=D server=server1
-	//key=E server=server2
-	//key=F server=server2
-	//key=G server=server1
+func Example_remove() {
 	ch := New()
 	ch.Add("server1")
 	ch.Add("server2")
@@ -229,15 +1595,31 @@ func Exampleremove() {
 	keys := []string{"A", "B", "C", "D", "E", "F", "G"}
 	fmt.Println("3 servers")
 	for _, key := range keys {
-		server, _ := ch.Get([]byte(key))
+		server, _ := ch.Get(KeyFromString(key))
 		fmt.Printf("key=%s server=%s\n", key, server)
 	}
 	fmt.Println("Removing server3")
 	ch.Remove("server3")
 	for _, key := range keys {
-		server, _ := ch.Get([]byte(key))
+		server, _ := ch.Get(KeyFromString(key))
 		fmt.Printf("key=%s server=%s\n", key, server)
 	}
+	// Output: 3 servers
+	// key=A server=server1
+	// key=B server=server2
+	// key=C server=server2
+	// key=D server=server1
+	// key=E server=server3
+	// key=F server=server2
+	// key=G server=server1
+	// Removing server3
+	// key=A server=server1
+	// key=B server=server2
+	// key=C server=server2
+	// key=D server=server1
+	// key=E server=server1
+	// key=F server=server2
+	// key=G server=server1
 }
 
 func bToMb(b uint64) uint64 {
@@ -260,22 +1642,62 @@ func TestSimpleHashRingMemoryUsage(t *testing.T) {
 	ch.Add("server2")
 	ch.Add("server3")
 	ch.Add("server4")
-	ch.Add("server4")
+	ch.Add("server5")
 	ch.Add("server6")
 	ch.Add("server7")
 
 	times := 100000000
 	PrintMemUsage()
 	for i := 0; i < times; i++ {
-		ch.Get([]byte(string(i)))
+		ch.Get(KeyFromInt(i))
 	}
 	PrintMemUsage()
 	for i := 0; i < times; i++ {
-		ch.Get([]byte(string(i)))
+		ch.Get(KeyFromInt(i))
 	}
 	PrintMemUsage()
 }
 
+// heapAlloc returns the bytes of live heap objects after a garbage collection
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// TestInternedMemoryUsage verifies that interning server names makes the ring smaller than storing a name per vnode
+func TestInternedMemoryUsage(t *testing.T) {
+	const servers, vnodeCount = 50, 500
+	before := heapAlloc()
+	ch := New(WithVnodeCount(vnodeCount))
+	addServers(ch, servers)
+	ring := heapAlloc() - before
+
+	// the same vnodes laid out with the owner name stored in every vnode
+	before = heapAlloc()
+	var positions []uint64
+	var owners []string
+	owned := make(map[string][]uint64)
+	for i := range ch.positions {
+		positions = append(positions, ch.positions[i])
+		owners = append(owners, ch.owner(i))
+		owned[ch.owner(i)] = append(owned[ch.owner(i)], ch.positions[i])
+	}
+	perVnode := heapAlloc() - before
+	t.Logf("interned ring: %d bytes, name per vnode: %d bytes", ring, perVnode)
+	assert.True(t, ring < perVnode)
+	runtime.KeepAlive(positions)
+	runtime.KeepAlive(owners)
+	runtime.KeepAlive(owned)
+
+	assert.Equal(t, servers*vnodeCount, ch.NumVnodes())
+	for _, key := range keys[:1000] {
+		server, _ := ch.Get(key)
+		assert.Equal(t, owners[linearClosest(ch, ch.hash(key))], server)
+	}
+}
+
 func TestRemapping(t *testing.T) {
 	ch := New()
 	ch.SetVnodeCount(200)
@@ -287,13 +1709,13 @@ func TestRemapping(t *testing.T) {
 	times := 200
 	var results []string
 	for i := 0; i < times; i++ {
-		val, _ := ch.Get([]byte(string(i)))
+		val, _ := ch.Get(KeyFromInt(i))
 		results = append(results, fmt.Sprintf("%d : %s", i, val))
 	}
 
 	var changes int
 	for i := 0; i < times; i++ {
-		val, _ := ch.Get([]byte(string(i)))
+		val, _ := ch.Get(KeyFromInt(i))
 		newResult := fmt.Sprintf("%d : %s", i, val)
 		if newResult != results[i] {
 			fmt.Printf("%s -> %s\n", results[i], newResult)
@@ -308,7 +1730,7 @@ func TestRemapping(t *testing.T) {
 	ch2.AddWithNodeCount("s4", 200)
 
 	for i := 0; i < times; i++ {
-		val, _ := ch2.Get([]byte(string(i)))
+		val, _ := ch2.Get(KeyFromInt(i))
 		newResult := fmt.Sprintf("%d : %s", i, val)
 		if newResult != results[i] {
 			changes = changes + 1
@@ -321,5 +1743,39 @@ func TestRemapping(t *testing.T) {
 }
 
 func TestFeature(t *testing.T) {
-	Examplebasic()
+	Example_basic()
+}
+
+// TestConcurrentAccess runs lookups while members are added and removed, run with -race to verify locking
+func TestConcurrentAccess(t *testing.T) {
+	ch := New()
+	ch.Add("server0")
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 8; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := keys[i%len(keys)]
+				ch.Get(key)
+				ch.Get2(key)
+				ch.GetN(key, 1)
+			}
+		}()
+	}
+	for i := 1; i < 50; i++ {
+		server := "server" + strconv.Itoa(i)
+		ch.Add(server)
+		ch.AddWithNodeCount(server+"b", 10)
+		ch.Remove(server + "b")
+	}
+	close(stop)
+	wg.Wait()
+	assert.Equal(t, 50*ch.vnodeCount, len(ch.positions))
 }
@@ -0,0 +1,37 @@
+package consistentHash
+
+import "context"
+
+// GetCtx finds the closest member for a given key, returning the context error instead if ctx is already done
+func (ch *ConsistentHash) GetCtx(ctx context.Context, key []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return ch.Get(key)
+}
+
+// GetBatchCtx is GetBatch but checks ctx before each key, returning the context error as soon as ctx is done
+func (ch *ConsistentHash) GetBatchCtx(ctx context.Context, keys [][]byte) ([]string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := ch.routable(); err != nil {
+		return nil, err
+	}
+	servers := make([]string, len(keys))
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		index, err := ch.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		if servers[i], err = ch.primary(index); err != nil {
+			return nil, err
+		}
+	}
+	return servers, nil
+}
@@ -0,0 +1,69 @@
+package consistentHash
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetCtx verifies that GetCtx matches Get and returns the context error once ctx is done
+func TestGetCtx(t *testing.T) {
+	ch := New()
+	addServers(ch, 3)
+	server, err := ch.GetCtx(context.Background(), []byte("key"))
+	assert.Nil(t, err)
+	expected, _ := ch.Get([]byte("key"))
+	assert.Equal(t, expected, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	server, err = ch.GetCtx(ctx, []byte("key"))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, "", server)
+
+	_, err = New().GetCtx(context.Background(), []byte("key"))
+	assert.Equal(t, ErrEmptyRing, err)
+}
+
+// TestGetBatchCtx verifies that GetBatchCtx matches GetBatch and stops between keys once ctx is done
+func TestGetBatchCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// cancel the context while the third key is being hashed
+	ch := New(WithHashFunc(func(data []byte) uint64 {
+		if string(data) == "key2" {
+			cancel()
+		}
+		return HashMurmur3(data)
+	}))
+	addServers(ch, 3)
+	keys := [][]byte{[]byte("key0"), []byte("key1")}
+	servers, err := ch.GetBatchCtx(context.Background(), keys)
+	assert.Nil(t, err)
+	expected, _ := ch.GetBatch(keys)
+	assert.Equal(t, expected, servers)
+
+	draining := New()
+	addServers(draining, 4)
+	draining.SetDraining("server1", true)
+	many := make([][]byte, 2000)
+	for i := range many {
+		many[i] = []byte("item" + strconv.Itoa(i))
+	}
+	servers, err = draining.GetBatchCtx(context.Background(), many)
+	assert.Nil(t, err)
+	expected, _ = draining.GetBatch(many)
+	assert.Equal(t, expected, servers)
+	assert.NotContains(t, servers, "server1")
+
+	keys = append(keys, []byte("key2"), []byte("key3"))
+	servers, err = ch.GetBatchCtx(ctx, keys)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, servers)
+
+	servers, err = ch.GetBatchCtx(ctx, keys[:1])
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, servers)
+}
@@ -12,7 +12,9 @@ See:
  http://en.wikipedia.org/wiki/MurmurHash
 
 
-The only time an error will be returned from a Get(), Get2(), or GetN() call is if there are not enough members added
+Get(), Get2() and GetN() return ErrEmptyRing if no members have been added, and Get2() and GetN() return ErrNotEnoughMembers if there are fewer members than asked for. Options and ring state add a few more: ErrOutOfRange for keys past the last vnode with WithNoWrap, ErrInsufficientNodes while the ring has fewer members than WithMinNodes requires, and ErrNoAvailableMembers from Get() when every member is draining. Each Err variable documents when it is returned
+
+GetN() lists members in ring order starting at the key. With the default CollisionTiebreak vnodes sharing a position are ordered by server name, so placement and GetN() lists only depend on the members and their vnode counts, never on the order they were added in or on the process building the ring
 
 Basic Example:
   ch := consistentHash.New()
@@ -20,18 +22,18 @@ Basic Example:
   ch.Add("server2")
   ch.Add("server3")
   for _,key := range []string{"A","B","C","D","E","F","G"} {
-	   server,err := ch.Get([]byte(key)
+	   server,err := ch.Get(consistentHash.KeyFromString(key))
 	   if err != nil {
 		  panic(err)
 	   }
-	   fmt.Println("key=%s server=%s\n",key,server)
+	   fmt.Printf("key=%s server=%s\n",key,server)
   }
   Outputs:
-	key=A server=server3
-	key=B server=server3
-	key=C server=server1
-	key=D server=server3
-	key=E server=server2
+	key=A server=server1
+	key=B server=server2
+	key=C server=server2
+	key=D server=server1
+	key=E server=server3
 	key=F server=server2
 	key=G server=server1
 
@@ -43,31 +45,30 @@ Basic Example:
 	keys := []string{"A", "B", "C", "D", "E", "F", "G"}
 	fmt.Println("3 servers")
 	for _, key := range keys {
-		server, _ := ch.Get([]byte(key))
+		server, _ := ch.Get(consistentHash.KeyFromString(key))
 		fmt.Printf("key=%s server=%s\n", key, server)
 	}
 	fmt.Println("Removing server3")
 	ch.Remove("server3")
 	for _, key := range keys {
-		server, _ := ch.Get([]byte(key))
+		server, _ := ch.Get(consistentHash.KeyFromString(key))
 		fmt.Printf("key=%s server=%s\n", key, server)
 	}
 	Output:
-	 Output:
 	3 servers
-	key=A server=server3
-	key=B server=server3
-	key=C server=server1
-	key=D server=server3
-	key=E server=server2
+	key=A server=server1
+	key=B server=server2
+	key=C server=server2
+	key=D server=server1
+	key=E server=server3
 	key=F server=server2
 	key=G server=server1
 	Removing server3
-	key=A server=server1  // remapped from 3->1
-	key=B server=server2  // remapped from 3->2
-	key=C server=server1  // stayed in same location
-	key=D server=server1  // remapped from 3->1
-	key=E server=server2  // stayed in same location
+	key=A server=server1  // stayed in same location
+	key=B server=server2  // stayed in same location
+	key=C server=server2  // stayed in same location
+	key=D server=server1  // stayed in same location
+	key=E server=server1  // remapped from 3->1
 	key=F server=server2  // stayed in same location
 	key=G server=server1  // stayed in same location
 
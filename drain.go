@@ -0,0 +1,67 @@
+package consistentHash
+
+// SetDraining marks a server as draining or returns it to normal, a draining server keeps its vnodes but Get and the
+// lookups built on it, such as GetBatch, GetDetailed, KeysOwnedBy and RingView.Get, skip it and route its keys to the
+// next member on the ring that is not draining
+// GetIncludingDraining still returns the original owner, so it can answer for keys it held during a migration
+// The flag is kept in memory only and is cleared when the server is removed
+// ErrNodeNotFound is returned if the server is not a member
+func (ch *ConsistentHash) SetDraining(address string, draining bool) error {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	if _, found := ch.nodeCount[address]; !found {
+		return ErrNodeNotFound
+	}
+	if draining {
+		ch.draining[address] = true
+	} else {
+		delete(ch.draining, address)
+	}
+	return nil
+}
+
+// Draining reports whether a server is marked as draining
+func (ch *ConsistentHash) Draining(address string) bool {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return ch.draining[address]
+}
+
+// GetIncludingDraining finds the closest member for a given key like Get, but without skipping draining servers
+func (ch *ConsistentHash) GetIncludingDraining(key []byte) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	index, err := ch.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return ch.owner(index), nil
+}
+
+// primary returns the first member at or after index that is not draining
+// ErrNoAvailableMembers is returned if every member is draining
+func (ch *ConsistentHash) primary(index int) (string, error) {
+	index, err := ch.serving(index)
+	if err != nil {
+		return "", err
+	}
+	return ch.owner(index), nil
+}
+
+// serving returns the index of the first vnode at or after index whose owner is not draining
+// ErrNoAvailableMembers is returned if every member is draining
+func (ch *ConsistentHash) serving(index int) (int, error) {
+	if len(ch.draining) == 0 {
+		return index, nil
+	}
+	for i := 0; i < len(ch.positions); i++ {
+		if !ch.draining[ch.owner(index)] {
+			return index, nil
+		}
+		index++
+		if index == len(ch.positions) {
+			index = 0
+		}
+	}
+	return 0, ErrNoAvailableMembers
+}
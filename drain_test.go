@@ -0,0 +1,51 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSetDraining verifies that draining a server reroutes Get to its successor while GetIncludingDraining keeps
+// returning the original owner, and that undraining restores the old mapping
+func TestSetDraining(t *testing.T) {
+	ch := New()
+	addServers(ch, 4)
+	before := ch.Clone()
+	assert.Equal(t, ErrNodeNotFound, ch.SetDraining("server9", true))
+
+	assert.Nil(t, ch.SetDraining("server1", true))
+	assert.True(t, ch.Draining("server1"))
+	assert.True(t, ch.Clone().Draining("server1"))
+	excluded := map[string]bool{"server1": true}
+	for _, key := range keys {
+		old, _ := before.Get(key)
+		original, err := ch.GetIncludingDraining(key)
+		assert.Nil(t, err)
+		assert.Equal(t, old, original)
+		server, err := ch.Get(key)
+		assert.Nil(t, err)
+		if old == "server1" {
+			next, _ := ch.GetExcluding(key, excluded)
+			assert.Equal(t, next, server)
+		} else {
+			assert.Equal(t, old, server)
+		}
+	}
+
+	assert.Nil(t, ch.SetDraining("server1", false))
+	assert.False(t, ch.Draining("server1"))
+	assertSameMapping(t, before, ch)
+
+	for _, server := range ch.Members() {
+		ch.SetDraining(server, true)
+	}
+	_, err := ch.Get(keys[0])
+	assert.Equal(t, ErrNoAvailableMembers, err)
+	_, err = ch.GetIncludingDraining(keys[0])
+	assert.Nil(t, err)
+
+	ch.Remove("server0")
+	ch.Add("server0")
+	assert.False(t, ch.Draining("server0"))
+}
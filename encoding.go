@@ -0,0 +1,229 @@
+package consistentHash
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	// maxEncodedNameLength bounds the server names ReadFrom accepts so corrupt data can't force a huge allocation
+	maxEncodedNameLength = 1 << 16
+)
+
+// ringState is the serialized form of a consistentHash
+// Only membership is stored, vnode positions are recomputed from it, so a ring is restored identically
+// as long as it is decoded with the same hash function it was encoded with
+type ringState struct {
+	VnodeCount int               `json:"vnodeCount"`
+	Members    map[string]int    `json:"members"`
+	Zones      map[string]string `json:"zones,omitempty"`
+}
+
+// state captures the membership of the consistentHash
+// The caller must hold at least the read lock
+func (ch *ConsistentHash) state() ringState {
+	state := ringState{
+		VnodeCount: ch.vnodeCount,
+		Members:    make(map[string]int, len(ch.nodeCount)),
+	}
+	for address, count := range ch.nodeCount {
+		state.Members[address] = count
+	}
+	if len(ch.zones) > 0 {
+		state.Zones = make(map[string]string, len(ch.zones))
+		for address, zone := range ch.zones {
+			state.Zones[address] = zone
+		}
+	}
+	return state
+}
+
+// restore rebuilds the consistentHash from a captured state, replacing the current membership
+// A zero value consistentHash, as allocated by encoding/json or encoding/gob for a field, gets the defaults of New
+// The ring is left unchanged if the state is invalid
+func (ch *ConsistentHash) restore(state ringState) error {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	if state.VnodeCount < 1 {
+		return ErrInvalidVnodeCount
+	}
+	if ch.hash == nil {
+		ch.init()
+	}
+	next := ch.blank()
+	next.vnodeCount = state.VnodeCount
+	addresses := make([]string, 0, len(state.Members))
+	for address := range state.Members {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if err := next.add(address, state.Members[address]); err != nil {
+			return err
+		}
+		if zone, found := state.Zones[address]; found {
+			next.zones[address] = zone
+		}
+	}
+	ch.adopt(next)
+	return nil
+}
+
+// MarshalJSON encodes the vnode count and each member with its own vnode count
+func (ch *ConsistentHash) MarshalJSON() ([]byte, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return json.Marshal(ch.state())
+}
+
+// UnmarshalJSON replaces the membership with that encoded by MarshalJSON
+// The hash function is not encoded, the receiving ring must be created with the same one, a zero value ring uses
+// the defaults of New
+func (ch *ConsistentHash) UnmarshalJSON(data []byte) error {
+	var state ringState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	return ch.restore(state)
+}
+
+// GobEncode encodes the vnode count and each member with its own vnode count
+func (ch *ConsistentHash) GobEncode() ([]byte, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ch.state()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode replaces the membership with that encoded by GobEncode and rebuilds the vnodes
+// The hash function is not encoded, the receiving ring must be created with the same one, a zero value ring such as
+// a field of an RPC argument uses the defaults of New
+func (ch *ConsistentHash) GobDecode(data []byte) error {
+	var state ringState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+	return ch.restore(state)
+}
+
+// WriteTo writes the ring in a compact binary form, implementing io.WriterTo
+// The format is a uvarint vnode count and a uvarint server count, then for each server in sorted order a
+// uvarint name length, the name and a uvarint vnode count
+// Zones are not written, use MarshalJSON or GobEncode to keep them
+func (ch *ConsistentHash) WriteTo(w io.Writer) (int64, error) {
+	ch.mutex.RLock()
+	addresses := make([]string, 0, len(ch.nodeCount))
+	for address := range ch.nodeCount {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64+len(addresses)*(16+2*binary.MaxVarintLen64))
+	buf = appendUvarint(buf, uint64(ch.vnodeCount))
+	buf = appendUvarint(buf, uint64(len(addresses)))
+	for _, address := range addresses {
+		buf = appendUvarint(buf, uint64(len(address)))
+		buf = append(buf, address...)
+		buf = appendUvarint(buf, uint64(ch.nodeCount[address]))
+	}
+	ch.mutex.RUnlock()
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadFrom replaces the membership with that written by WriteTo and rebuilds the vnodes, implementing io.ReaderFrom
+// Only the bytes of one encoded ring are read from r
+// The hash function is not encoded, the receiving ring must be created with the same one
+func (ch *ConsistentHash) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	state, err := readState(cr)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return cr.n, err
+	}
+	return cr.n, ch.restore(state)
+}
+
+// readState decodes a ring written by WriteTo
+func readState(r *countingReader) (ringState, error) {
+	var state ringState
+	vnodeCount, err := readCount(r)
+	if err != nil {
+		return state, err
+	}
+	servers, err := binary.ReadUvarint(r)
+	if err != nil {
+		return state, err
+	}
+	state.VnodeCount = vnodeCount
+	state.Members = make(map[string]int)
+	for i := uint64(0); i < servers; i++ {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return state, err
+		}
+		if length > maxEncodedNameLength {
+			return state, ErrCorruptEncoding
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return state, err
+		}
+		count, err := readCount(r)
+		if err != nil {
+			return state, err
+		}
+		if _, found := state.Members[string(name)]; found {
+			return state, ErrCorruptEncoding
+		}
+		state.Members[string(name)] = count
+	}
+	return state, nil
+}
+
+// readCount reads a uvarint vnode count, rejecting counts that don't fit in an int32
+func readCount(r *countingReader) (int, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if count > math.MaxInt32 {
+		return 0, ErrCorruptEncoding
+	}
+	return int(count), nil
+}
+
+// appendUvarint appends the uvarint encoding of v to buf
+func appendUvarint(buf []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	return append(buf, scratch[:binary.PutUvarint(scratch[:], v)]...)
+}
+
+// countingReader counts the bytes read from r and reads single bytes without buffering past the encoded ring
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(cr, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
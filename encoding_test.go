@@ -0,0 +1,169 @@
+package consistentHash
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertSameMapping(t *testing.T, expected, actual *ConsistentHash) {
+	for _, key := range keys[:1000] {
+		expectedServer, err := expected.Get(key)
+		assert.Nil(t, err)
+		actualServer, err := actual.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedServer, actualServer)
+	}
+}
+
+// TestJSONRoundTrip verifies that a ring restored from JSON maps keys identically
+func TestJSONRoundTrip(t *testing.T) {
+	ch := New()
+	ch.SetVnodeCount(150)
+	addServers(ch, 5)
+	ch.AddWithNodeCount("small", 20)
+	ch.AddWithZone("zoned", "zone1")
+	data, err := json.Marshal(ch)
+	assert.Nil(t, err)
+
+	restored := New()
+	restored.Add("stale")
+	assert.Nil(t, json.Unmarshal(data, restored))
+	assert.Equal(t, ch.Members(), restored.Members())
+	assert.Equal(t, 150, restored.vnodeCount)
+	assert.Equal(t, 20, restored.VnodeCount("small"))
+	assert.Equal(t, "zone1", restored.Zone("zoned"))
+	assert.Equal(t, ch.vnodes(), restored.vnodes())
+	assertSameMapping(t, ch, restored)
+}
+
+// TestJSONField verifies that a ring can be decoded into a zero value field allocated by encoding/json
+func TestJSONField(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	data, err := json.Marshal(struct{ Ring *ConsistentHash }{ch})
+	assert.Nil(t, err)
+	var decoded struct{ Ring *ConsistentHash }
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, ch.Members(), decoded.Ring.Members())
+	assert.Equal(t, ch.vnodes(), decoded.Ring.vnodes())
+	assertSameMapping(t, ch, decoded.Ring)
+	assert.Nil(t, decoded.Ring.Add("server5"))
+	assert.Nil(t, decoded.Ring.Validate())
+
+	zero := new(ConsistentHash)
+	assert.Nil(t, json.Unmarshal(data[len(`{"Ring":`):len(data)-1], zero))
+	assertSameMapping(t, ch, zero)
+}
+
+// TestJSONInvalid verifies that an invalid document leaves the ring unchanged
+func TestJSONInvalid(t *testing.T) {
+	ch := New()
+	ch.Add("server1")
+	assert.Equal(t, ErrInvalidVnodeCount, json.Unmarshal([]byte(`{"vnodeCount":0,"members":{"a":1}}`), ch))
+	assert.Equal(t, ErrInvalidVnodeCount, json.Unmarshal([]byte(`{"vnodeCount":10,"members":{"a":0}}`), ch))
+	assert.NotNil(t, json.Unmarshal([]byte(`{`), ch))
+	assert.Equal(t, []string{"server1"}, ch.Members())
+}
+
+// TestGobRoundTrip verifies that a ring sent through gob maps keys identically
+func TestGobRoundTrip(t *testing.T) {
+	ch := New()
+	addServers(ch, 5)
+	ch.AddWithNodeCount("small", 20)
+	ch.AddWithZone("zoned", "zone1")
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(ch))
+
+	restored := New()
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(restored))
+	assert.Equal(t, ch.Members(), restored.Members())
+	assert.Equal(t, 20, restored.VnodeCount("small"))
+	assert.Equal(t, "zone1", restored.Zone("zoned"))
+	assertSameMapping(t, ch, restored)
+}
+
+// TestGobField verifies that a ring sent as a field, like a ring in an RPC argument, is decoded into a zero value
+func TestGobField(t *testing.T) {
+	type args struct {
+		Ring *ConsistentHash
+	}
+	ch := New()
+	addServers(ch, 5)
+	ch.AddWithZone("zoned", "zone1")
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(args{ch}))
+
+	var decoded args
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(&decoded))
+	assert.Equal(t, ch.Members(), decoded.Ring.Members())
+	assert.Equal(t, "zone1", decoded.Ring.Zone("zoned"))
+	assertSameMapping(t, ch, decoded.Ring)
+	assert.Nil(t, decoded.Ring.Validate())
+}
+
+// TestBinaryRoundTrip verifies that a ring written with WriteTo and read back with ReadFrom maps keys identically
+func TestBinaryRoundTrip(t *testing.T) {
+	ch := New()
+	ch.SetVnodeCount(150)
+	addServers(ch, 5)
+	ch.AddWithNodeCount("small", 20)
+	var buf bytes.Buffer
+	written, err := ch.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), written)
+	// trailing data after the ring must be left in the reader
+	buf.WriteString("trailer")
+
+	restored := New()
+	restored.Add("stale")
+	read, err := restored.ReadFrom(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, written, read)
+	assert.Equal(t, "trailer", buf.String())
+	assert.Equal(t, ch.Members(), restored.Members())
+	assert.Equal(t, 20, restored.VnodeCount("small"))
+	assert.Nil(t, restored.Add("new"))
+	assert.Equal(t, 150, restored.VnodeCount("new"))
+}
+
+// TestBinaryMapping verifies that a reloaded ring gives the same Get results
+func TestBinaryMapping(t *testing.T) {
+	ch := New()
+	addServers(ch, 8)
+	var buf bytes.Buffer
+	_, err := ch.WriteTo(&buf)
+	assert.Nil(t, err)
+	restored := New()
+	_, err = restored.ReadFrom(&buf)
+	assert.Nil(t, err)
+	assertSameMapping(t, ch, restored)
+}
+
+// TestBinaryInvalid verifies that truncated or corrupt data is rejected and leaves the ring unchanged
+func TestBinaryInvalid(t *testing.T) {
+	ch := New()
+	addServers(ch, 2)
+	var buf bytes.Buffer
+	ch.WriteTo(&buf)
+	data := buf.Bytes()
+
+	restored := New()
+	restored.Add("server1")
+	_, err := restored.ReadFrom(bytes.NewReader(data[:len(data)-3]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = restored.ReadFrom(bytes.NewReader(nil))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	// a ring vnode count of 0, a server "a" with 0 vnodes and the server "a" listed twice
+	_, err = restored.ReadFrom(bytes.NewReader([]byte{0, 0}))
+	assert.Equal(t, ErrInvalidVnodeCount, err)
+	_, err = restored.ReadFrom(bytes.NewReader([]byte{1, 1, 1, 'a', 0}))
+	assert.Equal(t, ErrInvalidVnodeCount, err)
+	_, err = restored.ReadFrom(bytes.NewReader([]byte{1, 2, 1, 'a', 1, 1, 'a', 1}))
+	assert.Equal(t, ErrCorruptEncoding, err)
+	assert.Equal(t, []string{"server1"}, restored.Members())
+}
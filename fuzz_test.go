@@ -0,0 +1,66 @@
+//go:build go1.18
+// +build go1.18
+
+package consistentHash
+
+import (
+	"sort"
+	"testing"
+)
+
+// FuzzInsertRemove applies a sequence of insertVnode and removeVnode calls and checks the ring against a sorted
+// reference after every call
+// Each op is two bytes, the low bit of the first picks insert or remove and its next bits pick one of 3 addresses,
+// the second is the token, so the small token space produces plenty of adjacent and identical vnodes
+func FuzzInsertRemove(f *testing.F) {
+	f.Add([]byte{0, 10, 2, 10, 4, 10, 1, 10, 0, 11, 0, 9})
+	f.Add([]byte{0, 255, 0, 255, 1, 255, 1, 255, 1, 255})
+	f.Add([]byte{4, 0, 2, 0, 0, 0, 3, 0, 0, 1, 5, 0})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		ch := New()
+		var reference vnodes
+		for i := 0; i+1 < len(ops); i += 2 {
+			vn := vnode{uint64(ops[i+1]), []string{"a", "b", "c"}[ops[i]>>1%3]}
+			if ops[i]&1 == 0 {
+				ch.insertVnode(vn)
+				reference = append(reference, vn)
+				sort.Slice(reference, func(i, j int) bool {
+					return reference[i].token < reference[j].token ||
+						reference[i].token == reference[j].token && reference[i].address < reference[j].address
+				})
+			} else {
+				ch.removeVnode(vn)
+				for j := range reference {
+					if reference[j] == vn {
+						reference = append(reference[:j], reference[j+1:]...)
+						break
+					}
+				}
+			}
+			checkVnodes(t, ch, reference)
+		}
+	})
+}
+
+// checkVnodes fails the test unless the ring holds exactly the expected vnodes and its bookkeeping agrees with them
+func checkVnodes(t *testing.T, ch *ConsistentHash, expected vnodes) {
+	actual := ch.vnodes()
+	if len(actual) != len(expected) {
+		t.Fatalf("ring has %d vnodes, expected %d", len(actual), len(expected))
+	}
+	counts := make(map[string]int)
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Fatalf("vnode %d is %v, expected %v", i, actual[i], expected[i])
+		}
+		counts[actual[i].address]++
+	}
+	if len(ch.owned) != len(counts) || len(ch.ids) != len(counts) {
+		t.Fatalf("%d servers tracked and %d interned, expected %d", len(ch.owned), len(ch.ids), len(counts))
+	}
+	for address, count := range counts {
+		if len(ch.owned[address]) != count {
+			t.Fatalf("%s tracks %d vnodes, expected %d", address, len(ch.owned[address]), count)
+		}
+	}
+}
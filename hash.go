@@ -0,0 +1,43 @@
+package consistentHash
+
+import (
+	"hash/crc32"
+	"hash/fnv"
+
+	"github.com/cespare/xxhash"
+	"github.com/spaolacci/murmur3"
+)
+
+// Ready made hash functions for WithHashFunc()
+// Each is deterministic across processes and platforms, none of them depend on the host byte order
+
+// DefaultHash is the hash used by New() when no hash function is given, it is HashMurmur3
+// Replica i of a server is placed at DefaultHash("i=server") and a key at DefaultHash(key), so any MurmurHash3
+// implementation can reproduce the placement of a default ring in another language
+func DefaultHash(data []byte) uint64 {
+	return murmur3.Sum64(data)
+}
+
+// HashMurmur3 is the x64 128bit variant of MurmurHash3 with a seed of 0, truncated to its first 64bit half (h1)
+// This is DefaultHash
+func HashMurmur3(data []byte) uint64 {
+	return murmur3.Sum64(data)
+}
+
+// HashFNV1a is the 64bit FNV-1a hash
+func HashFNV1a(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// HashCRC32 is the IEEE CRC-32 checksum widened to 64bits, the upper 32bits are always 0
+// It has a much smaller range than the others so collisions between vnodes are far more likely
+func HashCRC32(data []byte) uint64 {
+	return uint64(crc32.ChecksumIEEE(data))
+}
+
+// HashXXHash is the 64bit xxHash (XXH64) with a seed of 0
+func HashXXHash(data []byte) uint64 {
+	return xxhash.Sum64(data)
+}
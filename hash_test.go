@@ -0,0 +1,60 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHashStability pins the output of the bundled hash functions so placements stay stable across releases
+func TestHashStability(t *testing.T) {
+	tests := []struct {
+		input   string
+		murmur3 uint64
+		fnv1a   uint64
+		crc32   uint64
+		xxhash  uint64
+	}{
+		{"", 0x0, 0xcbf29ce484222325, 0x0, 0xef46db3751d8e999},
+		{"server1", 0xdb52c19ce1a3455c, 0x34c90d949fadbbb9, 0xd758d54b, 0x363f3298f6ee59f4},
+		{"The quick brown fox jumps over the lazy dog", 0xe34bbc7bbc071b6c, 0xf3f9b7f5e7e47110, 0x414fa339, 0xb242d361fda71bc},
+	}
+	for _, test := range tests {
+		data := []byte(test.input)
+		assert.Equal(t, test.murmur3, HashMurmur3(data), test.input)
+		assert.Equal(t, test.fnv1a, HashFNV1a(data), test.input)
+		assert.Equal(t, test.crc32, HashCRC32(data), test.input)
+		assert.Equal(t, test.xxhash, HashXXHash(data), test.input)
+	}
+}
+
+// TestHashFuncOptions verifies that the bundled hash functions work with New()
+func TestHashFuncOptions(t *testing.T) {
+	def := New()
+	addServers(def, 5)
+	for _, fn := range []HashFunc{HashMurmur3, HashFNV1a, HashCRC32, HashXXHash} {
+		ch := New(WithHashFunc(fn), WithVnodeCount(100))
+		addServers(ch, 5)
+		assert.Equal(t, 500, len(ch.positions))
+		for _, key := range keys[:100] {
+			server, err := ch.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, ch.owner(ch.closest(fn(key))), server)
+		}
+	}
+	murmur := New(WithHashFunc(HashMurmur3))
+	addServers(murmur, 5)
+	assert.Equal(t, def.vnodes(), murmur.vnodes())
+}
+
+// TestDefaultHash verifies that New() uses DefaultHash and that its placement follows the documented vnode keys
+func TestDefaultHash(t *testing.T) {
+	def := New()
+	addServers(def, 5)
+	explicit := New(WithHashFunc(DefaultHash))
+	addServers(explicit, 5)
+	assert.True(t, def.Equal(explicit))
+	assert.Equal(t, HashMurmur3([]byte("server1")), DefaultHash([]byte("server1")))
+	assert.Contains(t, def.Positions("server1"), DefaultHash([]byte("0=server1")))
+	assert.Contains(t, def.Positions("server1"), DefaultHash([]byte("199=server1")))
+}
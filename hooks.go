@@ -0,0 +1,65 @@
+package consistentHash
+
+import "sync/atomic"
+
+// OnAdd registers fn to be called with the name of every server added by any call that adds members, such as Add,
+// AddBatch, SetMembers, AddAtPositions, Rename, Replace or AddShard
+// Hooks run once per server, after the change is visible and the write lock has been released, so fn may call back
+// into the consistentHash
+// Hooks are not copied by Clone and fire for every change of membership except Clear, Rebalance and decoding a
+// serialized ring
+func (ch *ConsistentHash) OnAdd(fn func(server string)) {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	ch.onAdd = append(ch.onAdd, fn)
+}
+
+// OnRemove registers fn to be called with the name of every server taken off the ring by any call that removes
+// members, such as Remove, RemoveBatch, SetMembers, RemoveWhere, RemoveWithReport, Rename, Replace or RemoveShard
+// It follows the same rules as OnAdd
+func (ch *ConsistentHash) OnRemove(fn func(server string)) {
+	ch.mutex.Lock()
+	defer ch.mutex.Unlock()
+	ch.onRemove = append(ch.onRemove, fn)
+}
+
+// update runs fn under the write lock and, unless it fails, calls the hooks for the servers it reports as added and
+// removed once the lock has been released
+func (ch *ConsistentHash) update(fn func() (added, removed []string, err error)) error {
+	ch.mutex.Lock()
+	addedServers, removedServers, err := fn()
+	onAdd, onRemove := ch.onAdd, ch.onRemove
+	ch.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&ch.metrics.adds, uint64(len(addedServers)))
+	atomic.AddUint64(&ch.metrics.removes, uint64(len(removedServers)))
+	for _, server := range removedServers {
+		for _, hook := range onRemove {
+			hook(server)
+		}
+	}
+	for _, server := range addedServers {
+		for _, hook := range onAdd {
+			hook(server)
+		}
+	}
+	return nil
+}
+
+// added is the result of an update that added a single server, unless err is set
+func added(address string, err error) ([]string, []string, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	return []string{address}, nil, nil
+}
+
+// removed is the result of an update that removed a single server, unless err is set
+func removed(address string, err error) ([]string, []string, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, []string{address}, nil
+}
@@ -0,0 +1,40 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	ch := New()
+	var addedServers, removedServers []string
+	ch.OnAdd(func(server string) {
+		addedServers = append(addedServers, server)
+		// hooks run without the lock held so they can use the ring
+		assert.True(t, ch.Contains(server))
+	})
+	ch.OnRemove(func(server string) {
+		removedServers = append(removedServers, server)
+		assert.False(t, ch.Contains(server))
+	})
+	ch.Add("server1")
+	ch.AddWithNodeCount("server2", 10)
+	ch.AddWithWeight("server3", 0.5)
+	ch.AddWithZone("server4", "zone1")
+	ch.Add("server1")
+	assert.Equal(t, []string{"server1", "server2", "server3", "server4"}, addedServers)
+
+	ch.Remove("server2")
+	ch.Remove("server2")
+	assert.Equal(t, []string{"server2"}, removedServers)
+
+	calls := 0
+	ch.OnAdd(func(string) { calls++ })
+	ch.Add("server5")
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 5, len(addedServers))
+
+	ch.Clone().Add("server6")
+	assert.Equal(t, 1, calls)
+}
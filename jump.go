@@ -0,0 +1,70 @@
+package consistentHash
+
+import "sync"
+
+// JumpHash maps key onto one of numBuckets buckets using Lamping and Veach's jump consistent hash
+// (https://arxiv.org/abs/1406.2294), it matches the reference implementation in the paper
+// Growing numBuckets from n to n+1 moves only 1/(n+1) of the keys, all of them into the new bucket
+// It returns -1 if numBuckets is less than 1
+func JumpHash(key uint64, numBuckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(numBuckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// JumpRing assigns keys to an ordered list of servers with JumpHash, needing no vnodes
+// Servers are numbered by the order they were added, and only the most recently added server can be removed
+// without remapping keys between the remaining servers
+type JumpRing struct {
+	mutex   sync.RWMutex
+	hash    HashFunc
+	servers []string
+}
+
+// NewJumpRing creates a JumpRing with the given servers as buckets 0..len(servers)-1
+// It accepts the same options as New() but only the hash function applies
+func NewJumpRing(servers []string, opts ...Option) *JumpRing {
+	return &JumpRing{
+		hash:    New(opts...).hash,
+		servers: append([]string{}, servers...),
+	}
+}
+
+// Add appends a server as the next bucket
+func (r *JumpRing) Add(address string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.servers = append(r.servers, address)
+}
+
+// RemoveLast removes the most recently added server, returning ErrEmptyRing if there are none
+func (r *JumpRing) RemoveLast() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.servers) == 0 {
+		return ErrEmptyRing
+	}
+	r.servers = r.servers[:len(r.servers)-1]
+	return nil
+}
+
+// Servers returns the servers in bucket order
+func (r *JumpRing) Servers() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]string{}, r.servers...)
+}
+
+// Get finds the server for a given key
+func (r *JumpRing) Get(key []byte) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if len(r.servers) == 0 {
+		return "", ErrEmptyRing
+	}
+	return r.servers[JumpHash(r.hash(key), len(r.servers))], nil
+}
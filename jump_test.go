@@ -0,0 +1,58 @@
+package consistentHash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestJumpHashGolden checks JumpHash against values from the reference implementation
+func TestJumpHashGolden(t *testing.T) {
+	tests := []struct {
+		key     uint64
+		buckets int
+		bucket  int
+	}{
+		{1, 1, 0},
+		{42, 57, 43},
+		{0xDEAD10CC, 1, 0},
+		{0xDEAD10CC, 666, 361},
+		{256, 1024, 520},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.bucket, JumpHash(test.key, test.buckets), "key=%d buckets=%d", test.key, test.buckets)
+	}
+	assert.Equal(t, -1, JumpHash(1, 0))
+}
+
+// TestJumpHashGrowth verifies that adding a bucket only moves keys into the new bucket
+func TestJumpHashGrowth(t *testing.T) {
+	for _, key := range keys {
+		token := HashMurmur3(key)
+		before := JumpHash(token, 10)
+		after := JumpHash(token, 11)
+		assert.True(t, after == before || after == 10)
+	}
+}
+
+func TestJumpRing(t *testing.T) {
+	r := NewJumpRing(nil)
+	_, err := r.Get(keys[0])
+	assert.Equal(t, ErrEmptyRing, err)
+	assert.Equal(t, ErrEmptyRing, r.RemoveLast())
+	servers := make([]string, 5)
+	for i := range servers {
+		servers[i] = "server" + strconv.Itoa(i)
+	}
+	r = NewJumpRing(servers, WithHashFunc(HashFNV1a))
+	for _, key := range keys[:100] {
+		server, err := r.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, servers[JumpHash(HashFNV1a(key), 5)], server)
+	}
+	r.Add("server5")
+	assert.Equal(t, 6, len(r.Servers()))
+	assert.Nil(t, r.RemoveLast())
+	assert.Equal(t, servers, r.Servers())
+}
@@ -0,0 +1,63 @@
+package consistentHash
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	// KetamaVnodeCount is the number of points libketama gives a server of weight 1
+	KetamaVnodeCount = 160
+	// KetamaPointsPerDigest is the number of 32bit points libketama takes from each MD5 digest
+	KetamaPointsPerDigest = 4
+)
+
+// WithKetamaCompat places vnodes and hashes keys the same way libketama and spymemcached's KetamaNodeLocator do,
+// so Get returns the same server as those clients given the same server strings (usually "host:port")
+// For each server the MD5 digest of "<server>-<n>" provides 4 points on the ring, keys are hashed with the first
+// 4 bytes of their MD5 digest read as a little endian uint32
+// The vnode count defaults to KetamaVnodeCount, AddWithNodeCount(server, 160*weight) reproduces a weighted libketama
+// server and counts are rounded up to a multiple of 4, see WithPointsPerDigest for variants taking fewer points
+// This replaces the hash function, so it should not be combined with WithHashFunc
+func WithKetamaCompat() Option {
+	return func(ch *ConsistentHash) {
+		ch.ketama = true
+		ch.hash = ketamaHash
+		ch.hash128 = nil
+		ch.lows = nil
+		ch.vnodeCount = KetamaVnodeCount
+	}
+}
+
+// WithPointsPerDigest sets how many 32bit points WithKetamaCompat takes from each MD5 digest of "<server>-<n>",
+// from 1 to 4, to match ketama variants that only use the first bytes of each digest
+// KetamaPointsPerDigest, the default, matches libketama and spymemcached, vnode counts are rounded up to a multiple
+// of n and other values of n are ignored, as is the option without WithKetamaCompat
+func WithPointsPerDigest(n int) Option {
+	return func(ch *ConsistentHash) {
+		if n >= 1 && n <= KetamaPointsPerDigest {
+			ch.pointsPerDigest = n
+		}
+	}
+}
+
+// ketamaHash is libketama's ketama_hashi
+func ketamaHash(key []byte) uint64 {
+	digest := md5.Sum(key)
+	return uint64(binary.LittleEndian.Uint32(digest[0:4]))
+}
+
+// ketamaTokens returns the points libketama's ketama_create_continuum places for a server, taking perDigest points
+// from each digest
+func ketamaTokens(address string, nodeCount int, perDigest int) []uint64 {
+	digests := (nodeCount + perDigest - 1) / perDigest
+	tokens := make([]uint64, 0, digests*perDigest)
+	for i := 0; i < digests; i++ {
+		digest := md5.Sum([]byte(address + "-" + strconv.Itoa(i)))
+		for h := 0; h < perDigest; h++ {
+			tokens = append(tokens, uint64(binary.LittleEndian.Uint32(digest[h*4:h*4+4])))
+		}
+	}
+	return tokens
+}
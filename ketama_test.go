@@ -0,0 +1,98 @@
+package consistentHash
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ketamaServers = []string{"10.0.1.1:11211", "10.0.1.2:11211", "10.0.1.3:11211", "10.0.1.4:11211"}
+
+// TestKetamaGolden checks placements against a reference vector produced by a port of libketama's
+// ketama_create_continuum and ketama_get_server for four servers of equal weight
+func TestKetamaGolden(t *testing.T) {
+	ch := New(WithKetamaCompat())
+	for _, server := range ketamaServers {
+		assert.Nil(t, ch.Add(server))
+		assert.Equal(t, KetamaVnodeCount, ch.VnodeCount(server))
+	}
+	tests := []struct {
+		key    string
+		server string
+	}{
+		{"foo", "10.0.1.2:11211"},
+		{"bar", "10.0.1.4:11211"},
+		{"baz", "10.0.1.2:11211"},
+		{"memcached", "10.0.1.3:11211"},
+		{"user:1", "10.0.1.1:11211"},
+		{"user:2", "10.0.1.4:11211"},
+		{"user:3", "10.0.1.4:11211"},
+		{"session:abc", "10.0.1.2:11211"},
+		{"0", "10.0.1.1:11211"},
+		{"1", "10.0.1.2:11211"},
+		{"2", "10.0.1.3:11211"},
+		{"3", "10.0.1.3:11211"},
+		{"4", "10.0.1.2:11211"},
+		{"5", "10.0.1.1:11211"},
+		{"the quick brown fox", "10.0.1.1:11211"},
+	}
+	for _, test := range tests {
+		server, err := ch.Get([]byte(test.key))
+		assert.Nil(t, err)
+		assert.Equal(t, test.server, server, test.key)
+	}
+}
+
+// TestKetamaPoints verifies the point layout for a single server
+func TestKetamaPoints(t *testing.T) {
+	ch := New(WithKetamaCompat())
+	ch.AddWithNodeCount("10.0.1.1:11211", 6)
+	// rounded up to two digests
+	assert.Equal(t, 8, len(ch.positions))
+	digest := md5.Sum([]byte("10.0.1.1:11211-1"))
+	for h := 0; h < 4; h++ {
+		point := uint64(binary.LittleEndian.Uint32(digest[h*4:]))
+		index := ch.index(point)
+		assert.Equal(t, point, ch.positions[index])
+	}
+	assert.Nil(t, ch.Remove("10.0.1.1:11211"))
+	assert.Empty(t, ch.vnodes())
+
+	digest = md5.Sum([]byte("foo"))
+	assert.Equal(t, uint64(binary.LittleEndian.Uint32(digest[:4])), ketamaHash([]byte("foo")))
+
+	// clones keep ketama placement
+	ch.Add("10.0.1.2:11211")
+	clone := ch.Clone()
+	clone.Clear()
+	clone.Add("10.0.1.2:11211")
+	assert.Equal(t, ch.vnodes(), clone.vnodes())
+	for _, position := range clone.positions {
+		assert.True(t, position <= 0xffffffff)
+	}
+}
+
+// TestPointsPerDigest verifies that one point per digest takes the first point of each digest, giving a different
+// ring from the libketama layout
+func TestPointsPerDigest(t *testing.T) {
+	one := New(WithKetamaCompat(), WithPointsPerDigest(1))
+	four := New(WithKetamaCompat(), WithPointsPerDigest(4))
+	def := New(WithKetamaCompat(), WithPointsPerDigest(5))
+	for _, ch := range []*ConsistentHash{one, four, def} {
+		ch.AddWithNodeCount("10.0.1.1:11211", 6)
+	}
+	assert.Equal(t, 6, len(one.positions))
+	assert.Equal(t, 8, len(four.positions))
+	assert.Equal(t, four.vnodes(), def.vnodes())
+	assert.False(t, one.Equal(four))
+	digest := md5.Sum([]byte("10.0.1.1:11211-5"))
+	assert.Contains(t, one.Positions("10.0.1.1:11211"), uint64(binary.LittleEndian.Uint32(digest[:4])))
+
+	one.AddWithNodeCount("10.0.1.2:11211", 160)
+	four.AddWithNodeCount("10.0.1.2:11211", 160)
+	assert.Equal(t, 160, one.VnodeCount("10.0.1.2:11211"))
+	assert.NotEqual(t, one.Positions("10.0.1.2:11211"), four.Positions("10.0.1.2:11211"))
+	assert.Equal(t, one.vnodes(), one.Clone().vnodes())
+}
@@ -0,0 +1,32 @@
+package consistentHash
+
+import "encoding/binary"
+
+// KeyFromString returns the key for a string, its UTF-8 bytes
+func KeyFromString(key string) []byte {
+	return []byte(key)
+}
+
+// KeyFromUint64 returns the key for an integer, its 8 byte little-endian encoding, as used by GetUint64
+func KeyFromUint64(key uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, key)
+	return buf
+}
+
+// KeyFromInt returns the key for an int, the encoding of KeyFromUint64 with negative keys in two's complement,
+// as used by GetInt
+// Unlike []byte(string(i)), which encodes i as a UTF-8 rune and turns every invalid rune into the same key,
+// every int gets its own key
+func KeyFromInt(key int) []byte {
+	return KeyFromUint64(uint64(int64(key)))
+}
+
+// KeyFromNamespace returns the key for key within namespace, as used by GetNamespaced
+// The namespace is length prefixed, so no namespace and key pair can produce the key of another pair
+func KeyFromNamespace(namespace string, key []byte) []byte {
+	buf := make([]byte, 0, binary.MaxVarintLen64+len(namespace)+len(key))
+	buf = appendUvarint(buf, uint64(len(namespace)))
+	buf = append(buf, namespace...)
+	return append(buf, key...)
+}
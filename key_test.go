@@ -0,0 +1,54 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKeyFrom verifies the documented key encodings and that distinct ints never share a key
+func TestKeyFrom(t *testing.T) {
+	assert.Equal(t, []byte("testKey"), KeyFromString("testKey"))
+	assert.Equal(t, []byte{1, 2, 0, 0, 0, 0, 0, 0}, KeyFromUint64(0x0201))
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, KeyFromInt(-1))
+	assert.Equal(t, KeyFromUint64(12345), KeyFromInt(12345))
+
+	// every surrogate is an invalid rune, so string(i) gives them all the same key
+	assert.Equal(t, []byte(string(rune(0xd800))), []byte(string(rune(0xdfff))))
+	assert.NotEqual(t, KeyFromInt(0xd800), KeyFromInt(0xdfff))
+	seen := make(map[string]bool)
+	for i := -1000; i < 100000; i++ {
+		seen[string(KeyFromInt(i))] = true
+	}
+	assert.Equal(t, 101000, len(seen))
+
+	ch := New()
+	addServers(ch, 5)
+	expected, _ := ch.GetInt(42)
+	server, _ := ch.Get(KeyFromInt(42))
+	assert.Equal(t, expected, server)
+}
+
+// TestGetNamespaced verifies that a key maps consistently within a namespace and independently across namespaces
+func TestGetNamespaced(t *testing.T) {
+	assert.Equal(t, []byte("\x02abkey"), KeyFromNamespace("ab", []byte("key")))
+	assert.NotEqual(t, KeyFromNamespace("a", []byte("bc")), KeyFromNamespace("ab", []byte("c")))
+
+	ch := New()
+	addServers(ch, 10)
+	different := 0
+	for _, key := range keys {
+		users, err := ch.GetNamespaced("users", key)
+		assert.Nil(t, err)
+		again, _ := ch.GetNamespaced("users", key)
+		assert.Equal(t, users, again)
+		expected, _ := ch.Get(KeyFromNamespace("users", key))
+		assert.Equal(t, expected, users)
+		orders, _ := ch.GetNamespaced("orders", key)
+		if users != orders {
+			different++
+		}
+	}
+	// independent placements agree for about one key in ten with 10 servers
+	assert.InDelta(t, 0.9, float64(different)/float64(len(keys)), 0.05)
+}
@@ -0,0 +1,35 @@
+package consistentHash
+
+import "sync/atomic"
+
+// counters are the atomically updated counts behind Metrics
+type counters struct {
+	lookups uint64
+	wraps   uint64
+	adds    uint64
+	removes uint64
+}
+
+// RingMetrics are cumulative counts of what a consistentHash has done since it was created
+type RingMetrics struct {
+	// Lookups is the number of keys or hashes looked up, a GetBatch of 10 keys counts 10
+	// Lookups through a RingView are not counted, so that snapshot readers on different cores never contend on the
+	// counters
+	Lookups uint64
+	// Wraps is the number of lookups past the last vnode that wrapped around to the first, or failed with WithNoWrap
+	Wraps uint64
+	// Adds and Removes are the number of servers that joined and left, as reported to OnAdd and OnRemove hooks
+	Adds    uint64
+	Removes uint64
+}
+
+// Metrics returns the counters of the consistentHash, reading them is cheap and takes no lock
+// A Clone or Snapshot starts with its own counters at 0
+func (ch *ConsistentHash) Metrics() RingMetrics {
+	return RingMetrics{
+		Lookups: atomic.LoadUint64(&ch.metrics.lookups),
+		Wraps:   atomic.LoadUint64(&ch.metrics.wraps),
+		Adds:    atomic.LoadUint64(&ch.metrics.adds),
+		Removes: atomic.LoadUint64(&ch.metrics.removes),
+	}
+}
@@ -0,0 +1,41 @@
+package consistentHash
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMetrics verifies that lookups, wrap arounds and membership changes are counted
+func TestMetrics(t *testing.T) {
+	ch := New(WithHashFunc(func(data []byte) uint64 {
+		if string(data) == "past" {
+			return math.MaxUint64
+		}
+		return HashMurmur3(data)
+	}))
+	assert.Equal(t, RingMetrics{}, ch.Metrics())
+	addServers(ch, 3)
+	ch.Remove("server1")
+	ch.AddBatch([]string{"a", "b"})
+	ch.Add("a")
+
+	for _, key := range keys[:1000] {
+		ch.Get(key)
+	}
+	ch.Get([]byte("past"))
+	ch.GetBatch(keys[:10])
+	metrics := ch.Metrics()
+	assert.Equal(t, uint64(1011), metrics.Lookups)
+	assert.True(t, metrics.Wraps >= 1)
+	assert.Equal(t, uint64(5), metrics.Adds)
+	assert.Equal(t, uint64(1), metrics.Removes)
+	assert.Equal(t, RingMetrics{}, ch.Clone().Metrics())
+
+	view := ch.Snapshot()
+	view.Get(keys[0])
+	view.GetN(keys[0], 2)
+	assert.Equal(t, metrics, ch.Metrics())
+	assert.Equal(t, RingMetrics{}, view.ring.Metrics())
+}
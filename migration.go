@@ -0,0 +1,76 @@
+package consistentHash
+
+// MigrationPlan compares the owners of keys on two rings, typically the live ring and a Clone() with pending
+// membership changes applied
+// The result maps every key whose owner changes to its [old, new] owners, an empty owner means the ring had no members
+func MigrationPlan(from, to *ConsistentHash, keys [][]byte) map[string][2]string {
+	plan := make(map[string][2]string)
+	for _, key := range keys {
+		oldOwner, _ := from.Get(key)
+		newOwner, _ := to.Get(key)
+		if oldOwner != newOwner {
+			plan[string(key)] = [2]string{oldOwner, newOwner}
+		}
+	}
+	return plan
+}
+
+// RemoveReport describes the keys that moved when RemoveWithReport took a server off the ring
+type RemoveReport struct {
+	// Removed is false if the server was not a member, the ring is then left unchanged
+	Removed bool
+	// Moved is the number of sampled keys whose owner changed
+	Moved int
+	// NewOwners maps every sampled key that moved to its owner after the removal
+	NewOwners map[string]string
+}
+
+// RemoveWithReport removes a server like Remove and reports which of sampleKeys changed owner, which estimates the
+// churn of the removal, for instance to size a cache warm up
+// Owners are compared before and after under the same write lock, so concurrent changes do not show up in the report
+// Only keys the removed server owned can move
+func (ch *ConsistentHash) RemoveWithReport(address string, sampleKeys [][]byte) RemoveReport {
+	report := RemoveReport{NewOwners: make(map[string]string)}
+	ch.update(func() ([]string, []string, error) {
+		if _, found := ch.nodeCount[address]; !found {
+			return nil, nil, ErrNodeNotFound
+		}
+		before := make([]string, len(sampleKeys))
+		for i, key := range sampleKeys {
+			if index, err := ch.lookup(key); err == nil {
+				before[i], _ = ch.primary(index)
+			}
+		}
+		ch.remove(address)
+		for i, key := range sampleKeys {
+			after := ""
+			if index, err := ch.lookup(key); err == nil {
+				after, _ = ch.primary(index)
+			}
+			if after != before[i] {
+				report.NewOwners[string(key)] = after
+				report.Moved++
+			}
+		}
+		report.Removed = true
+		return nil, []string{address}, nil
+	})
+	return report
+}
+
+// SimulateChange applies a membership change to a Clone() and returns, for every server, how many of keys would
+// move to it, the live ring is left unchanged and hooks do not fire
+// Servers that no key moves to are left out, an empty destination means the changed ring has no members
+func (ch *ConsistentHash) SimulateChange(apply func(*ConsistentHash), keys [][]byte) map[string]int {
+	next := ch.Clone()
+	apply(next)
+	moves := make(map[string]int)
+	for _, key := range keys {
+		oldOwner, _ := ch.Get(key)
+		newOwner, _ := next.Get(key)
+		if oldOwner != newOwner {
+			moves[newOwner]++
+		}
+	}
+	return moves
+}
@@ -0,0 +1,81 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMigrationPlanRemove verifies that removing a server only moves the keys it owned
+func TestMigrationPlanRemove(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	next := ch.Clone()
+	next.Remove("server5")
+	plan := MigrationPlan(ch, next, keys)
+	assert.NotEmpty(t, plan)
+	owned := 0
+	for _, key := range keys {
+		server, _ := ch.Get(key)
+		if server == "server5" {
+			owned++
+		}
+	}
+	assert.Equal(t, owned, len(plan))
+	for _, move := range plan {
+		assert.Equal(t, "server5", move[0])
+		assert.NotEqual(t, "server5", move[1])
+	}
+	assert.Empty(t, MigrationPlan(ch, ch.Clone(), keys))
+}
+
+// TestRemoveWithReport verifies that the report lists exactly the sampled keys the removed server owned
+func TestRemoveWithReport(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	before := ch.Clone()
+	report := ch.RemoveWithReport("server5", keys)
+	assert.True(t, report.Removed)
+	assert.False(t, ch.Contains("server5"))
+	assert.Equal(t, MigrationPlan(before, ch, keys), func() map[string][2]string {
+		plan := make(map[string][2]string)
+		for key, owner := range report.NewOwners {
+			plan[key] = [2]string{"server5", owner}
+		}
+		return plan
+	}())
+	assert.Equal(t, len(report.NewOwners), report.Moved)
+	for _, key := range keys {
+		old, _ := before.Get(key)
+		server, _ := ch.Get(key)
+		if old == "server5" {
+			assert.Equal(t, server, report.NewOwners[string(key)])
+		}
+	}
+
+	report = ch.RemoveWithReport("server5", keys)
+	assert.False(t, report.Removed)
+	assert.Equal(t, 0, report.Moved)
+	assert.Equal(t, 9, ch.Size())
+}
+
+// TestSimulateChange verifies that adding a server only draws keys towards it and leaves the live ring alone
+func TestSimulateChange(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	moves := ch.SimulateChange(func(next *ConsistentHash) { next.Add("server10") }, keys)
+	assert.Equal(t, 1, len(moves))
+	assert.InDelta(t, float64(len(keys))/11, float64(moves["server10"]), float64(len(keys))/50)
+	assert.False(t, ch.Contains("server10"))
+	next := ch.Clone()
+	next.Add("server10")
+	assert.Equal(t, len(MigrationPlan(ch, next, keys)), moves["server10"])
+
+	moves = ch.SimulateChange(func(next *ConsistentHash) { next.Remove("server3") }, keys)
+	assert.Equal(t, 0, moves["server3"])
+	total := 0
+	for _, count := range moves {
+		total += count
+	}
+	assert.Equal(t, len(ch.KeysOwnedBy("server3", keys)), total)
+}
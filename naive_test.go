@@ -0,0 +1,107 @@
+package consistentHash
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// naiveRing is a reference consistent hash that keeps every vnode in one sorted slice and scans it linearly
+// It shares nothing with ConsistentHash except the vnode keys, so it pins placement against layout changes
+type naiveRing struct {
+	vnodes []vnode
+}
+
+func newNaiveRing(hash HashFunc, members map[string]int) *naiveRing {
+	ring := new(naiveRing)
+	for address, count := range members {
+		for i := 0; i < count; i++ {
+			ring.vnodes = append(ring.vnodes, vnode{hash(addressToKey(address, i)), address})
+		}
+	}
+	sort.Slice(ring.vnodes, func(i, j int) bool {
+		if ring.vnodes[i].token != ring.vnodes[j].token {
+			return ring.vnodes[i].token < ring.vnodes[j].token
+		}
+		return ring.vnodes[i].address < ring.vnodes[j].address
+	})
+	return ring
+}
+
+// getN returns the first count distinct servers at or after the key's hash, wrapping around the ring
+func (ring *naiveRing) getN(hash uint64, count int) []string {
+	start := 0
+	for start < len(ring.vnodes) && ring.vnodes[start].token < hash {
+		start++
+	}
+	seen := make(map[string]bool)
+	var servers []string
+	for i := 0; i < len(ring.vnodes) && len(servers) < count; i++ {
+		address := ring.vnodes[(start+i)%len(ring.vnodes)].address
+		if !seen[address] {
+			seen[address] = true
+			servers = append(servers, address)
+		}
+	}
+	return servers
+}
+
+// TestNaiveRing verifies that Get, Get2 and GetN agree with the naive reference ring for several memberships
+func TestNaiveRing(t *testing.T) {
+	many := make(map[string]int)
+	for i := 0; i < 25; i++ {
+		many["server"+strconv.Itoa(i)] = DefaultVnodeCount
+	}
+	tests := []struct {
+		name    string
+		hash    HashFunc
+		members map[string]int
+		removed []string
+	}{
+		{"two servers", HashMurmur3, map[string]int{"server1": DefaultVnodeCount, "server2": DefaultVnodeCount}, nil},
+		{"many servers", HashMurmur3, many, nil},
+		{"weighted", HashMurmur3, map[string]int{"small": 10, "medium": 200, "large": 1000}, nil},
+		{"after removal", HashMurmur3, map[string]int{"a": 100, "c": 100, "e": 100}, []string{"b", "d"}},
+		{"colliding", collidingHash, map[string]int{"a": 10, "b": 10, "c": 10}, nil},
+		{"xxhash", HashXXHash, map[string]int{"server1": 50, "server2": 50, "server3": 50}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ch := New(WithHashFunc(test.hash))
+			addresses := make([]string, 0, len(test.members)+len(test.removed))
+			for address := range test.members {
+				addresses = append(addresses, address)
+			}
+			sort.Strings(addresses)
+			for _, address := range append(addresses, test.removed...) {
+				count, found := test.members[address]
+				if !found {
+					count = 50
+				}
+				assert.Nil(t, ch.AddWithNodeCount(address, count))
+			}
+			for _, address := range test.removed {
+				assert.Nil(t, ch.Remove(address))
+			}
+			naive := newNaiveRing(test.hash, test.members)
+			count := 3
+			if len(test.members) < count {
+				count = len(test.members)
+			}
+			for _, key := range keys[:2000] {
+				expected := naive.getN(test.hash(key), count)
+				server, err := ch.Get(key)
+				assert.Nil(t, err)
+				assert.Equal(t, expected[0], server)
+				first, second, err := ch.Get2(key)
+				assert.Nil(t, err)
+				assert.Equal(t, expected[:2], []string{first, second})
+				servers, err := ch.GetN(key, count)
+				assert.Nil(t, err)
+				assert.Equal(t, expected, servers)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package consistentHash
+
+import "github.com/spaolacci/murmur3"
+
+// Option configures a ConsistentHash when passed to New()
+type Option func(*ConsistentHash)
+
+// WithHashFunc sets the hash used for both vnode placement and key lookups
+// A nil fn leaves DefaultHash in place
+func WithHashFunc(fn HashFunc) Option {
+	return func(ch *ConsistentHash) {
+		if fn != nil {
+			ch.hash = fn
+			ch.hash128 = nil
+			ch.lows = nil
+		}
+	}
+}
+
+// WithVnodeCount sets the number of vnodes added for every server, the same as calling SetVnodeCount() before any Add()
+// A count below 1 leaves DefaultVnodeCount in place
+func WithVnodeCount(count int) Option {
+	return func(ch *ConsistentHash) {
+		if count > 0 {
+			ch.vnodeCount = count
+		}
+	}
+}
+
+// WithSeed mixes seed into the key of every vnode, so rings with different seeds place the same servers differently
+// while rings with the same seed, hash function and members are always identical, in any process
+// Only vnode placement is seeded, keys are looked up with the plain hash
+// The default seed is 0, which leaves vnode keys unchanged, and the seed is ignored with WithKetamaCompat
+func WithSeed(seed uint64) Option {
+	return func(ch *ConsistentHash) {
+		ch.seed = seed
+	}
+}
+
+// WithHash128 gives vnodes and keys 128bit positions hashed with fn, a nil fn uses the 128bit murmur3 hash
+// Positions are still ordered by their high 64 bits first, the low 64 bits only break ties, which keeps vnodes
+// from colliding on very large rings at the cost of 8 more bytes per vnode
+// APIs that take or report a position such as GetByHash, Positions and Walk only use the high 64 bits
+func WithHash128(fn Hash128Func) Option {
+	return func(ch *ConsistentHash) {
+		if fn == nil {
+			fn = murmur3.Sum128
+		}
+		ch.hash128 = fn
+		ch.hash = func(data []byte) uint64 {
+			high, _ := fn(data)
+			return high
+		}
+		ch.lows = make([]uint64, 0)
+		ch.ketama = false
+	}
+}
+
+// WithReplicaKeyFunc sets how the key hashed to place each vnode is derived from the server and the replica number,
+// counting from 0, to match the convention of another implementation
+// The default key is the replica number, "=" and the server, a nil fn leaves it in place and fn is ignored with
+// WithKetamaCompat
+func WithReplicaKeyFunc(fn func(server string, replica int) []byte) Option {
+	return func(ch *ConsistentHash) {
+		if fn != nil {
+			ch.replicaKey = fn
+		}
+	}
+}
+
+// WithNoWrap turns the ring into a line, a key past the last vnode is an error instead of going to the first vnode
+// This models a partitioned keyspace where each vnode owns the half-open range from the previous vnode up to and
+// including its own position, lookups of keys past the last vnode return ErrOutOfRange
+// Lookups of several members that start within range still walk past the last vnode to the first
+func WithNoWrap() Option {
+	return func(ch *ConsistentHash) {
+		ch.noWrap = true
+	}
+}
+
+// WithMinNodes makes lookups fail with ErrInsufficientNodes while the ring has fewer than n members, so that a
+// single server surviving a partition does not take all the traffic
+// The check comes before any other, so an empty ring or a GetN for more servers than there are members also
+// returns ErrInsufficientNodes, a count below 2 has no effect
+func WithMinNodes(n int) Option {
+	return func(ch *ConsistentHash) {
+		ch.minNodes = n
+	}
+}
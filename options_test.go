@@ -0,0 +1,267 @@
+package consistentHash
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/spaolacci/murmur3"
+	"github.com/stretchr/testify/assert"
+)
+
+func fnv64a(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+func addServers(ch *ConsistentHash, count int) {
+	for i := 0; i < count; i++ {
+		ch.Add("server" + strconv.Itoa(i))
+	}
+}
+
+// TestWithHashFunc verifies that rings sharing a hash function agree and that a different function changes placement
+func TestWithHashFunc(t *testing.T) {
+	c1 := New(WithHashFunc(fnv64a))
+	c2 := New(WithHashFunc(fnv64a))
+	c3 := New()
+	addServers(c1, 10)
+	addServers(c2, 10)
+	addServers(c3, 10)
+	assert.Equal(t, c1.vnodes(), c2.vnodes())
+	assert.NotEqual(t, c1.vnodes(), c3.vnodes())
+	for _, key := range keys {
+		s1, err := c1.Get(key)
+		assert.Nil(t, err)
+		s2, _ := c2.Get(key)
+		assert.Equal(t, s1, s2)
+	}
+}
+
+// TestWithNilHashFunc verifies that a nil hash function keeps the default
+func TestWithNilHashFunc(t *testing.T) {
+	c1 := New(WithHashFunc(nil))
+	c2 := New()
+	addServers(c1, 3)
+	addServers(c2, 3)
+	assert.Equal(t, c1.vnodes(), c2.vnodes())
+}
+
+func TestWithVnodeCount(t *testing.T) {
+	ch := New(WithVnodeCount(50))
+	ch.Add("server1")
+	assert.Equal(t, 50, ch.VnodeCount("server1"))
+	assert.Equal(t, 50, len(ch.positions))
+	assert.Equal(t, DefaultVnodeCount, New(WithVnodeCount(0)).vnodeCount)
+}
+
+// TestSetVnodeCountAfterAdd verifies that the vnode count cannot change once members are present
+func TestSetVnodeCountAfterAdd(t *testing.T) {
+	ch := New()
+	assert.Equal(t, ErrInvalidVnodeCount, ch.SetVnodeCount(0))
+	assert.Nil(t, ch.SetVnodeCount(10))
+	ch.Add("server1")
+	assert.Equal(t, ErrNotAvailableOnceMembersAdded, ch.SetVnodeCount(20))
+	ch.Add("server2")
+	assert.Equal(t, 10, ch.VnodeCount("server2"))
+	ch.Remove("server1")
+	ch.Remove("server2")
+	assert.Nil(t, ch.SetVnodeCount(20))
+}
+
+// TestWithSeed verifies that rings built with the same seed map keys identically and that the seed changes placement
+func TestWithSeed(t *testing.T) {
+	c1 := New(WithSeed(7))
+	c2 := New(WithSeed(7))
+	addServers(c1, 5)
+	addServers(c2, 5)
+	assert.Equal(t, c1.positions, c2.positions)
+	assertSameMapping(t, c1, c2)
+	assertSameMapping(t, c1, c1.Clone())
+
+	c3 := New(WithSeed(8))
+	addServers(c3, 5)
+	assert.NotEqual(t, c1.positions, c3.positions)
+
+	// the default seed keeps the unseeded placement
+	c4 := New(WithSeed(0))
+	c5 := New()
+	addServers(c4, 5)
+	addServers(c5, 5)
+	assert.Equal(t, c5.positions, c4.positions)
+}
+
+// countCollisions returns the number of vnodes whose full position equals that of the vnode before them
+func countCollisions(ch *ConsistentHash) int {
+	collisions := 0
+	for i := 1; i < len(ch.positions); i++ {
+		if ch.positions[i] == ch.positions[i-1] && (ch.lows == nil || ch.lows[i] == ch.lows[i-1]) {
+			collisions++
+		}
+	}
+	return collisions
+}
+
+// TestWithHash128 verifies that a 128bit ring keeps itself ordered and maps keys to the first vnode at or after them
+func TestWithHash128(t *testing.T) {
+	ch := New(WithHash128(nil), WithVnodeCount(50))
+	addServers(ch, 10)
+	assert.Nil(t, ch.Validate())
+	assert.Equal(t, 500, len(ch.lows))
+	for _, key := range keys[:1000] {
+		high, low := murmur3.Sum128(key)
+		expected := 0
+		for i := range ch.positions {
+			if ch.positions[i] > high || ch.positions[i] == high && ch.lows[i] >= low {
+				expected = i
+				break
+			}
+		}
+		server, err := ch.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, ch.owner(expected), server)
+	}
+	assertSameMapping(t, ch, ch.Clone())
+	ch.Remove("server3")
+	ch.Rename("server4", "renamed")
+	assert.Nil(t, ch.Validate())
+	assert.Equal(t, 450, len(ch.lows))
+	assert.Nil(t, New(WithHash128(nil), WithHashFunc(fnv64a)).lows)
+}
+
+// TestWithHash128Collisions verifies that the low 64 bits separate vnodes whose high bits collide
+// Full 64bit positions almost never collide at 50k vnodes, so the high bits are cut to 24 to make collisions common
+func TestWithHash128Collisions(t *testing.T) {
+	truncated := func(data []byte) (uint64, uint64) {
+		high, low := murmur3.Sum128(data)
+		return high >> 40, low
+	}
+	c64 := New(WithHashFunc(func(data []byte) uint64 {
+		high, _ := truncated(data)
+		return high
+	}), WithVnodeCount(500))
+	c128 := New(WithHash128(truncated), WithVnodeCount(500))
+	addServers(c64, 100)
+	addServers(c128, 100)
+	assert.Equal(t, c64.positions, c128.positions)
+	collisions64, collisions128 := countCollisions(c64), countCollisions(c128)
+	t.Logf("collisions in 50000 vnodes: %d with 64bit positions, %d with 128bit positions", collisions64, collisions128)
+	assert.True(t, collisions64 > 0)
+	assert.Equal(t, 0, collisions128)
+	assert.Nil(t, c128.Validate())
+}
+
+// TestWithReplicaKeyFunc verifies that the replica key changes placement and is hashed exactly as given
+func TestWithReplicaKeyFunc(t *testing.T) {
+	colon := func(server string, replica int) []byte {
+		return []byte(fmt.Sprintf("%s:%d", server, replica))
+	}
+	dash := func(server string, replica int) []byte {
+		return []byte(fmt.Sprintf("%s-%d", server, replica))
+	}
+	c1 := New(WithHashFunc(fnv64a), WithReplicaKeyFunc(colon), WithVnodeCount(20))
+	c2 := New(WithHashFunc(fnv64a), WithReplicaKeyFunc(dash), WithVnodeCount(20))
+	addServers(c1, 3)
+	addServers(c2, 3)
+	assert.NotEqual(t, c1.positions, c2.positions)
+
+	// the positions an implementation hashing "server:replica" with FNV-1a would use
+	var expected []uint64
+	for i := 0; i < 3; i++ {
+		for replica := 0; replica < 20; replica++ {
+			expected = append(expected, fnv64a([]byte(fmt.Sprintf("server%d:%d", i, replica))))
+		}
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	assert.Equal(t, expected, c1.positions)
+
+	c3 := New(WithReplicaKeyFunc(nil))
+	c4 := New()
+	addServers(c3, 3)
+	addServers(c4, 3)
+	assert.Equal(t, c4.positions, c3.positions)
+}
+
+// TestWithNoWrap verifies that keys past the last vnode wrap around by default and are out of range with WithNoWrap
+func TestWithNoWrap(t *testing.T) {
+	hash := func(data []byte) uint64 {
+		if string(data) == "past" {
+			return math.MaxUint64
+		}
+		return HashMurmur3(data)
+	}
+	wrapping := New(WithHashFunc(hash))
+	bounded := New(WithHashFunc(hash), WithNoWrap())
+	addServers(wrapping, 3)
+	addServers(bounded, 3)
+	past := []byte("past")
+
+	server, err := wrapping.Get(past)
+	assert.Nil(t, err)
+	assert.Equal(t, wrapping.owner(0), server)
+
+	_, err = bounded.Get(past)
+	assert.Equal(t, ErrOutOfRange, err)
+	_, err = bounded.GetByHash(math.MaxUint64)
+	assert.Equal(t, ErrOutOfRange, err)
+	_, err = bounded.GetN(past, 2)
+	assert.Equal(t, ErrOutOfRange, err)
+	_, err = bounded.GetBatch([][]byte{[]byte("key"), past})
+	assert.Equal(t, ErrOutOfRange, err)
+	assert.Empty(t, bounded.KeysOwnedBy(server, [][]byte{past}))
+
+	// keys up to the last vnode are unaffected
+	last := bounded.positions[len(bounded.positions)-1]
+	for _, key := range keys {
+		expected, _ := wrapping.Get(key)
+		server, err := bounded.Get(key)
+		if bounded.hash(key) > last {
+			assert.Equal(t, ErrOutOfRange, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, expected, server)
+	}
+	server, err = bounded.GetByHash(last)
+	assert.Nil(t, err)
+	assert.Equal(t, bounded.owner(len(bounded.positions)-1), server)
+	_, err = bounded.GetByHash(last + 1)
+	assert.Equal(t, ErrOutOfRange, err)
+	assert.True(t, bounded.Clone().noWrap)
+}
+
+// TestWithMinNodes verifies that lookups fail below the minimum and succeed once it is reached
+func TestWithMinNodes(t *testing.T) {
+	ch := New(WithMinNodes(3))
+	_, err := ch.Get(keys[0])
+	assert.Equal(t, ErrInsufficientNodes, err)
+	_, err = ch.GetN(keys[0], 2)
+	assert.Equal(t, ErrInsufficientNodes, err)
+	ch.Add("server0")
+	_, err = ch.GetN(keys[0], 2)
+	assert.Equal(t, ErrInsufficientNodes, err)
+	ch.Add("server1")
+	_, err = ch.Get(keys[0])
+	assert.Equal(t, ErrInsufficientNodes, err)
+	_, err = ch.GetN(keys[0], 1)
+	assert.Equal(t, ErrInsufficientNodes, err)
+	_, err = ch.GetByHash(42)
+	assert.Equal(t, ErrInsufficientNodes, err)
+
+	ch.Add("server2")
+	_, err = ch.Get(keys[0])
+	assert.Nil(t, err)
+	servers, err := ch.GetN(keys[0], 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(servers))
+	_, err = ch.Clone().Get(keys[0])
+	assert.Nil(t, err)
+
+	ch.Remove("server2")
+	_, err = ch.Get(keys[0])
+	assert.Equal(t, ErrInsufficientNodes, err)
+}
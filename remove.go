@@ -0,0 +1,68 @@
+package consistentHash
+
+// RemoveWhere removes every server for which pred returns true in one step and returns their names in sorted order
+// The vnodes of all of them are filtered out in a single pass over the ring, which is much cheaper than one Remove
+// per server when taking out a whole rack
+// pred is called with the write lock held, so it must not call back into the consistentHash
+// OnRemove hooks are called for each removed server
+func (ch *ConsistentHash) RemoveWhere(pred func(server string) bool) []string {
+	var removedServers []string
+	ch.update(func() ([]string, []string, error) {
+		for _, address := range ch.sortedMembers() {
+			if pred(address) {
+				removedServers = append(removedServers, address)
+			}
+		}
+		ch.removeAll(removedServers)
+		return nil, removedServers, nil
+	})
+	if removedServers == nil {
+		return []string{}
+	}
+	return removedServers
+}
+
+// removeAll takes several members and all of their vnodes off the ring in a single pass
+// The caller must hold the write lock
+func (ch *ConsistentHash) removeAll(addresses []string) {
+	if len(addresses) == 0 {
+		return
+	}
+	removing := make(map[uint32]bool, len(addresses))
+	leaving := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		if id, found := ch.ids[address]; found {
+			removing[id] = true
+		}
+		leaving[address] = true
+	}
+	kept := 0
+	for i := range ch.positions {
+		if removing[ch.owners[i]] {
+			continue
+		}
+		ch.positions[kept] = ch.positions[i]
+		ch.owners[kept] = ch.owners[i]
+		ch.replicas[kept] = ch.replicas[i]
+		if ch.lows != nil {
+			ch.lows[kept] = ch.lows[i]
+		}
+		kept++
+	}
+	ch.positions = ch.positions[:kept]
+	ch.owners = ch.owners[:kept]
+	ch.replicas = ch.replicas[:kept]
+	if ch.lows != nil {
+		ch.lows = ch.lows[:kept]
+	}
+	for _, address := range addresses {
+		delete(ch.owned, address)
+		ch.release(address)
+		delete(ch.nodeCount, address)
+		delete(ch.zones, address)
+		delete(ch.draining, address)
+	}
+	if ch.tracker != nil {
+		ch.tracker.retain(func(assigned string) bool { return !leaving[assigned] })
+	}
+}
@@ -0,0 +1,52 @@
+package consistentHash
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRemoveWhere verifies that removing a rack by prefix leaves the same ring as removing its servers one by one
+func TestRemoveWhere(t *testing.T) {
+	ch := New()
+	expected := New()
+	for i := 0; i < 4; i++ {
+		for _, rack := range []string{"rack1-", "rack2-", "rack3-"} {
+			ch.AddWithZone(rack+strconv.Itoa(i), rack)
+			expected.Add(rack + strconv.Itoa(i))
+		}
+	}
+	ch.SetDraining("rack2-1", true)
+	var hooked []string
+	ch.OnRemove(func(server string) { hooked = append(hooked, server) })
+	removed := ch.RemoveWhere(func(server string) bool { return strings.HasPrefix(server, "rack2-") })
+	assert.Equal(t, []string{"rack2-0", "rack2-1", "rack2-2", "rack2-3"}, removed)
+	assert.Equal(t, removed, hooked)
+	for _, server := range removed {
+		expected.Remove(server)
+		assert.False(t, ch.Contains(server))
+		assert.Equal(t, "", ch.Zone(server))
+		assert.False(t, ch.Draining(server))
+	}
+	assert.True(t, expected.Equal(ch))
+	assert.Nil(t, ch.Validate())
+	assert.Equal(t, 8, ch.Size())
+
+	assert.Equal(t, []string{}, ch.RemoveWhere(func(string) bool { return false }))
+	ch.Add("rack2-0")
+	assert.Equal(t, 200, ch.VnodeCount("rack2-0"))
+	assert.Nil(t, ch.Validate())
+}
+
+// Benchmark_RemoveWhere tests how fast a rack of 10 servers is removed from a ring of 100 servers
+func Benchmark_RemoveWhere(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		ch := New()
+		addServers(ch, 100)
+		b.StartTimer()
+		ch.RemoveWhere(func(server string) bool { return strings.HasSuffix(server, "7") })
+	}
+}
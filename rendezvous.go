@@ -0,0 +1,77 @@
+package consistentHash
+
+import (
+	"sort"
+	"sync"
+)
+
+// RendezvousRing implements rendezvous, or highest random weight, hashing
+// Each key goes to the member with the highest hash of member and key, which spreads keys evenly without vnodes and
+// uses memory proportional to the number of members, at the cost of O(members) lookups
+// It is a good fit for small clusters where the memory used by vnodes matters more than lookup speed
+type RendezvousRing struct {
+	mutex   sync.RWMutex
+	hash    HashFunc
+	members []string
+}
+
+// NewRendezvous creates a new RendezvousRing
+// It accepts the same options as New() but only the hash function applies
+func NewRendezvous(opts ...Option) *RendezvousRing {
+	return &RendezvousRing{
+		hash:    New(opts...).hash,
+		members: make([]string, 0),
+	}
+}
+
+// Add adds a server to the ring, ErrNodeExists is returned if it has already been added
+func (r *RendezvousRing) Add(address string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index := sort.SearchStrings(r.members, address)
+	if index < len(r.members) && r.members[index] == address {
+		return ErrNodeExists
+	}
+	r.members = append(r.members, "")
+	copy(r.members[index+1:], r.members[index:])
+	r.members[index] = address
+	return nil
+}
+
+// Remove removes a server from the ring, ErrNodeNotFound is returned if it is not a member
+func (r *RendezvousRing) Remove(address string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index := sort.SearchStrings(r.members, address)
+	if index == len(r.members) || r.members[index] != address {
+		return ErrNodeNotFound
+	}
+	r.members = append(r.members[:index], r.members[index+1:]...)
+	return nil
+}
+
+// Members returns the servers in the ring, sorted by name
+func (r *RendezvousRing) Members() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]string{}, r.members...)
+}
+
+// Get finds the member with the highest score for a given key, ties go to the member that sorts first
+func (r *RendezvousRing) Get(key []byte) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if len(r.members) == 0 {
+		return "", ErrEmptyRing
+	}
+	var best string
+	var bestScore uint64
+	buf := make([]byte, 0, 64)
+	for i, address := range r.members {
+		buf = append(append(append(buf[:0], address...), '='), key...)
+		if score := r.hash(buf); i == 0 || score > bestScore {
+			best, bestScore = address, score
+		}
+	}
+	return best, nil
+}
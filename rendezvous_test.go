@@ -0,0 +1,72 @@
+package consistentHash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/GaryBoone/GoStats/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRendezvous(t *testing.T) {
+	r := NewRendezvous()
+	_, err := r.Get([]byte("testKey"))
+	assert.Equal(t, ErrEmptyRing, err)
+	assert.Nil(t, r.Add("server2"))
+	assert.Nil(t, r.Add("server1"))
+	assert.Equal(t, ErrNodeExists, r.Add("server1"))
+	assert.Equal(t, []string{"server1", "server2"}, r.Members())
+	assert.Nil(t, r.Remove("server2"))
+	assert.Equal(t, ErrNodeNotFound, r.Remove("server2"))
+	server, err := r.Get([]byte("testKey"))
+	assert.Nil(t, err)
+	assert.Equal(t, "server1", server)
+}
+
+// TestRendezvousRemap verifies that removing a server only moves the keys it owned
+func TestRendezvousRemap(t *testing.T) {
+	r := NewRendezvous(WithHashFunc(HashXXHash))
+	serverCount := 10
+	for i := 0; i < serverCount; i++ {
+		r.Add("server" + strconv.Itoa(i))
+	}
+	before := make([]string, len(keys))
+	for i, key := range keys {
+		before[i], _ = r.Get(key)
+	}
+	r.Remove("server5")
+	for i, key := range keys {
+		server, _ := r.Get(key)
+		if before[i] != "server5" {
+			assert.Equal(t, before[i], server)
+		} else {
+			assert.NotEqual(t, "server5", server)
+		}
+	}
+}
+
+// TestRendezvousDistribution compares the spread of keys with the vnode ring
+// This is mostly informational
+func TestRendezvousDistribution(t *testing.T) {
+	serverCount := 10
+	r := NewRendezvous()
+	ch := New()
+	for i := 0; i < serverCount; i++ {
+		r.Add("server" + strconv.Itoa(i))
+		ch.Add("server" + strconv.Itoa(i))
+	}
+	counts := make(map[string]int)
+	for _, key := range keys {
+		server, _ := r.Get(key)
+		counts[server]++
+	}
+	stat := stats.Stats{}
+	for _, count := range counts {
+		stat.Update(float64(count))
+	}
+	ring := ch.Stats(keys)
+	t.Logf("Stddev for %d keys mapped across %d servers: rendezvous=%.2f vnode ring=%.2f", len(keys), serverCount, stat.PopulationStandardDeviation(), ring.StdDev)
+	assert.Equal(t, serverCount, len(counts))
+	// with 10000 keys over 10 servers the sampling noise alone is about 30 keys
+	assert.True(t, stat.PopulationStandardDeviation() < 100)
+}
@@ -0,0 +1,95 @@
+//go:build go1.18
+// +build go1.18
+
+package consistentHash
+
+import "sync"
+
+// Ring is a consistentHash whose members carry a value of type T, Get returns the value instead of the member id
+// Placement is identical to a ConsistentHash built with the same options and ids
+type Ring[T comparable] struct {
+	ch    *ConsistentHash
+	mutex sync.RWMutex
+	nodes map[string]T
+}
+
+// NewRing creates a new Ring, options are the same as those accepted by New()
+func NewRing[T comparable](opts ...Option) *Ring[T] {
+	return &Ring[T]{
+		ch:    New(opts...),
+		nodes: make(map[string]T),
+	}
+}
+
+// Add adds a node to the ring under id with the configured vnode count
+func (r *Ring[T]) Add(id string, node T) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.ch.Add(id); err != nil {
+		return err
+	}
+	r.nodes[id] = node
+	return nil
+}
+
+// AddWithNodeCount adds a node to the ring under id with nodeCount vnodes
+func (r *Ring[T]) AddWithNodeCount(id string, node T, nodeCount int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.ch.AddWithNodeCount(id, nodeCount); err != nil {
+		return err
+	}
+	r.nodes[id] = node
+	return nil
+}
+
+// Remove removes the node added under id
+func (r *Ring[T]) Remove(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.ch.Remove(id); err != nil {
+		return err
+	}
+	delete(r.nodes, id)
+	return nil
+}
+
+// Node returns the node added under id
+func (r *Ring[T]) Node(id string) (T, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	node, found := r.nodes[id]
+	return node, found
+}
+
+// Get finds the closest node for a given key
+func (r *Ring[T]) Get(key []byte) (T, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	id, err := r.ch.Get(key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return r.nodes[id], nil
+}
+
+// GetN finds the closest N distinct nodes for a given key, in ring order
+func (r *Ring[T]) GetN(key []byte, count int) ([]T, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	ids, err := r.ch.GetN(key, count)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]T, len(ids))
+	for i, id := range ids {
+		nodes[i] = r.nodes[id]
+	}
+	return nodes, nil
+}
+
+// Members returns the ids of the nodes in the ring, sorted
+func (r *Ring[T]) Members() []string {
+	return r.ch.Members()
+}
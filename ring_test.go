@@ -0,0 +1,57 @@
+//go:build go1.18
+// +build go1.18
+
+package consistentHash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNode struct {
+	Addr string
+	Port int
+}
+
+func TestRingInt(t *testing.T) {
+	r := NewRing[int]()
+	_, err := r.Get(keys[0])
+	assert.Equal(t, ErrNoMembers, err)
+	ch := New()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, r.Add("server"+strconv.Itoa(i), i))
+		ch.Add("server" + strconv.Itoa(i))
+	}
+	assert.Equal(t, ErrNodeExists, r.Add("server0", 10))
+	for _, key := range keys {
+		node, err := r.Get(key)
+		assert.Nil(t, err)
+		id, _ := ch.Get(key)
+		assert.Equal(t, "server"+strconv.Itoa(node), id)
+	}
+	assert.Nil(t, r.Remove("server0"))
+	_, found := r.Node("server0")
+	assert.False(t, found)
+	assert.Equal(t, ErrNodeNotFound, r.Remove("server0"))
+}
+
+func TestRingStruct(t *testing.T) {
+	r := NewRing[testNode](WithHashFunc(fnv64a))
+	r.Add("a", testNode{"10.0.0.1", 11211})
+	r.AddWithNodeCount("b", testNode{"10.0.0.2", 11211}, 50)
+	r.Add("c", testNode{"10.0.0.3", 11211})
+	assert.Equal(t, []string{"a", "b", "c"}, r.Members())
+	node, found := r.Node("b")
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.2", node.Addr)
+
+	nodes, err := r.GetN(keys[0], 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(nodes))
+	primary, _ := r.Get(keys[0])
+	assert.Equal(t, primary, nodes[0])
+	_, err = r.GetN(keys[0], 4)
+	assert.Equal(t, ErrNotEnoughMembers, err)
+}
@@ -0,0 +1,56 @@
+package consistentHash
+
+import "math"
+
+// Segment is an arc of the ring whose keys all map to Server, from just after Start up to and including End
+// The arc of the first segment wraps past the top of the ring, so its Start is above its End, and a segment whose
+// Start equals its End covers the whole ring
+type Segment struct {
+	Start  uint64
+	End    uint64
+	Server string
+}
+
+// Size returns the number of positions in the segment, modulo 2^64, so the whole ring has size 0
+func (s Segment) Size() uint64 {
+	return s.End - s.Start
+}
+
+// Share returns the fraction of the ring the segment covers, 1 for the whole ring
+func (s Segment) Share() float64 {
+	if s.Start == s.End {
+		return 1
+	}
+	return float64(s.Size()) / math.Pow(2, 64)
+}
+
+// Segments returns the arcs of the ring in ring order, each a maximal run of positions owned by one server
+// Consecutive vnodes of the same server form a single segment, including across the top of the ring, and vnodes
+// sharing a position with an earlier vnode own no positions and are left out, so the sizes add up to 2^64
+// Segments describes the wrapping ring, with WithNoWrap the part of the first segment above the last vnode is
+// unowned
+func (ch *ConsistentHash) Segments() []Segment {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if len(ch.positions) == 0 {
+		return []Segment{}
+	}
+	last := ch.positions[len(ch.positions)-1]
+	segments := []Segment{{Start: last, End: ch.positions[0], Server: ch.owner(0)}}
+	for i := 1; i < len(ch.positions); i++ {
+		if ch.positions[i] == ch.positions[i-1] {
+			continue
+		}
+		current := &segments[len(segments)-1]
+		if server := ch.owner(i); server == current.Server {
+			current.End = ch.positions[i]
+		} else {
+			segments = append(segments, Segment{Start: ch.positions[i-1], End: ch.positions[i], Server: server})
+		}
+	}
+	if tail := segments[len(segments)-1]; len(segments) > 1 && tail.Server == segments[0].Server {
+		segments[0].Start = tail.Start
+		segments = segments[:len(segments)-1]
+	}
+	return segments
+}
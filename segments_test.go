@@ -0,0 +1,45 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSegments verifies that the segments cover the whole ring, alternate owners and agree with OwnerAt
+func TestSegments(t *testing.T) {
+	ch := New()
+	assert.Equal(t, []Segment{}, ch.Segments())
+	addServers(ch, 5)
+	segments := ch.Segments()
+	total := uint64(0)
+	share := 0.0
+	for i, segment := range segments {
+		total += segment.Size()
+		share += segment.Share()
+		next := segments[(i+1)%len(segments)]
+		assert.Equal(t, segment.End, next.Start)
+		assert.NotEqual(t, segment.Server, next.Server)
+		owner, _ := ch.OwnerAt(segment.End)
+		assert.Equal(t, segment.Server, owner)
+		owner, _ = ch.OwnerAt(segment.Start + 1)
+		assert.Equal(t, segment.Server, owner)
+	}
+	// 2^64 wraps to 0
+	assert.Equal(t, uint64(0), total)
+	assert.InDelta(t, 1.0, share, 1e-9)
+	assert.True(t, len(segments) < len(ch.positions))
+}
+
+// TestSegmentsMerge verifies that adjacent vnodes of one server, including across the top of the ring, merge
+func TestSegmentsMerge(t *testing.T) {
+	ch := New()
+	ch.AddAtPositions("a", []uint64{10, 20, 1 << 63})
+	assert.Equal(t, []Segment{{1 << 63, 1 << 63, "a"}}, ch.Segments())
+	assert.Equal(t, 1.0, ch.Segments()[0].Share())
+
+	ch.AddAtPositions("b", []uint64{30, 40})
+	ch.AddAtPositions("c", []uint64{40})
+	assert.Equal(t, []Segment{{40, 20, "a"}, {20, 40, "b"}}, ch.Segments())
+	assert.Equal(t, uint64(0), ch.Segments()[0].Size()+ch.Segments()[1].Size())
+}
@@ -0,0 +1,43 @@
+package consistentHash
+
+import (
+	"strconv"
+	"strings"
+)
+
+// shardPrefix starts the member name of every shard, so shards cannot be confused with servers named by a number
+const shardPrefix = "shard:"
+
+// ShardName returns the member name AddShard gives shard index, "shard:<index>"
+func ShardName(index int) string {
+	return shardPrefix + strconv.Itoa(index)
+}
+
+// AddShard adds shard index as a member with the configured vnode count, so that GetShard can return its number
+// ErrNodeExists is returned if the shard has already been added
+func (ch *ConsistentHash) AddShard(index int) error {
+	return ch.Add(ShardName(index))
+}
+
+// RemoveShard removes shard index from the ring
+// ErrNodeNotFound is returned if the shard is not a member
+func (ch *ConsistentHash) RemoveShard(index int) error {
+	return ch.Remove(ShardName(index))
+}
+
+// GetShard finds the closest member for a given key like Get and returns its shard index
+// It returns the same errors as Get and ErrNotShard if the key maps to a member not added with AddShard
+func (ch *ConsistentHash) GetShard(key []byte) (int, error) {
+	server, err := ch.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(server, shardPrefix) {
+		return 0, ErrNotShard
+	}
+	index, err := strconv.Atoi(server[len(shardPrefix):])
+	if err != nil {
+		return 0, ErrNotShard
+	}
+	return index, nil
+}
@@ -0,0 +1,50 @@
+package consistentHash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetShard verifies that keys map to the shard of their member and spread evenly over 16 shards
+func TestGetShard(t *testing.T) {
+	ch := New()
+	_, err := ch.GetShard(keys[0])
+	assert.Equal(t, ErrEmptyRing, err)
+	for i := 0; i < 16; i++ {
+		assert.Nil(t, ch.AddShard(i))
+	}
+	assert.Equal(t, ErrNodeExists, ch.AddShard(3))
+	assert.True(t, ch.Contains("shard:3"))
+
+	counts := make(map[int]int)
+	for _, key := range keys {
+		shard, err := ch.GetShard(key)
+		assert.Nil(t, err)
+		server, _ := ch.Get(key)
+		assert.Equal(t, ShardName(shard), server)
+		counts[shard]++
+	}
+	assert.Equal(t, 16, len(counts))
+	for shard, count := range counts {
+		assert.InDelta(t, float64(len(keys))/16, float64(count), float64(len(keys))/16*0.4, "shard %d", shard)
+	}
+
+	// a ring built again from the same shards assigns every key the same shard
+	rebuilt := New()
+	for i := 15; i >= 0; i-- {
+		rebuilt.AddShard(i)
+	}
+	for _, key := range keys[:1000] {
+		expected, _ := ch.GetShard(key)
+		shard, _ := rebuilt.GetShard(key)
+		assert.Equal(t, expected, shard)
+	}
+
+	assert.Nil(t, ch.RemoveShard(3))
+	assert.Equal(t, ErrNodeNotFound, ch.RemoveShard(3))
+	other := New()
+	other.Add("3")
+	_, err = other.GetShard(keys[0])
+	assert.Equal(t, ErrNotShard, err)
+}
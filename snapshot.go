@@ -0,0 +1,41 @@
+package consistentHash
+
+// RingView is an immutable snapshot of a consistentHash, its lookups take no locks
+// A read heavy service can keep the current view in an atomic.Value and store a new one from Snapshot after each
+// membership change, readers then never wait on writers
+type RingView struct {
+	// ring is a private copy that is never modified, so it can be read without its lock
+	ring *ConsistentHash
+}
+
+// Snapshot returns a view of the current ring, later changes to the consistentHash do not affect it
+// Lookups on the view are not counted in Metrics, see RingMetrics
+func (ch *ConsistentHash) Snapshot() *RingView {
+	ring := ch.Clone()
+	ring.uncounted = true
+	return &RingView{ring: ring}
+}
+
+// Get finds the closest member for a given key, like ConsistentHash.Get
+func (v *RingView) Get(key []byte) (string, error) {
+	index, err := v.ring.lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return v.ring.primary(index)
+}
+
+// GetN finds the closest N distinct members for a given key, like ConsistentHash.GetN
+func (v *RingView) GetN(key []byte, count int) ([]string, error) {
+	return v.ring.getN(key, count)
+}
+
+// Members returns the sorted members of the view
+func (v *RingView) Members() []string {
+	return v.ring.Members()
+}
+
+// Size returns the number of members in the view
+func (v *RingView) Size() int {
+	return len(v.ring.nodeCount)
+}
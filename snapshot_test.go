@@ -0,0 +1,91 @@
+package consistentHash
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnapshot verifies that a view maps keys like the ring it was taken from and ignores later changes
+func TestSnapshot(t *testing.T) {
+	_, err := New().Snapshot().Get([]byte("testKey"))
+	assert.Equal(t, ErrEmptyRing, err)
+	ch := New()
+	addServers(ch, 5)
+	view := ch.Snapshot()
+	assert.Equal(t, ch.Members(), view.Members())
+	assert.Equal(t, 5, view.Size())
+	for _, key := range keys[:1000] {
+		expected, _ := ch.Get(key)
+		server, err := view.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, server)
+		expectedN, _ := ch.GetN(key, 3)
+		servers, err := view.GetN(key, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedN, servers)
+	}
+	ch.Remove("server0")
+	ch.Add("server5")
+	assert.Equal(t, 5, view.Size())
+	assert.Contains(t, view.Members(), "server0")
+	assert.NotContains(t, view.Members(), "server5")
+
+	ch.SetDraining("server1", true)
+	view = ch.Snapshot()
+	for _, key := range keys[:1000] {
+		expected, _ := ch.Get(key)
+		server, err := view.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, server)
+		assert.NotEqual(t, "server1", server)
+	}
+}
+
+// contend keeps changing the membership of ch until stop is closed, calling changed after every change
+func contend(ch *ConsistentHash, stop chan bool, changed func()) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+			ch.Add("extra")
+			changed()
+			ch.Remove("extra")
+			changed()
+		}
+	}
+}
+
+// Benchmark_ContendedGet tests how fast parallel lookups on the ring are while its membership changes
+func Benchmark_ContendedGet(b *testing.B) {
+	ch := New()
+	addServers(ch, 10)
+	stop := make(chan bool)
+	defer close(stop)
+	go contend(ch, stop, func() {})
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			ch.Get(keys[i%len(keys)])
+		}
+	})
+}
+
+// Benchmark_SnapshotGet tests how fast parallel lookups on a snapshot are while the ring's membership changes
+func Benchmark_SnapshotGet(b *testing.B) {
+	ch := New()
+	addServers(ch, 10)
+	var view atomic.Value
+	view.Store(ch.Snapshot())
+	stop := make(chan bool)
+	defer close(stop)
+	go contend(ch, stop, func() { view.Store(ch.Snapshot()) })
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			view.Load().(*RingView).Get(keys[i%len(keys)])
+		}
+	})
+}
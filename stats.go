@@ -0,0 +1,125 @@
+package consistentHash
+
+import "math"
+
+// DistributionStats describes how a set of keys is spread across the members of a consistentHash
+type DistributionStats struct {
+	// Counts is the number of keys mapped to each member, members that received no keys are included with 0
+	Counts map[string]int
+	// Mean is the average number of keys per member
+	Mean float64
+	// StdDev is the population standard deviation of the per member counts
+	StdDev float64
+	// Min and Max are the smallest and largest per member counts
+	Min int
+	Max int
+	// CV is the coefficient of variation, StdDev / Mean, a scale free measure of imbalance where 0 is perfectly even
+	CV float64
+}
+
+// Stats maps keys onto the consistentHash and reports how evenly they are distributed
+// A sample of production keys gives a good picture of the balance actually achieved
+func (ch *ConsistentHash) Stats(keys [][]byte) DistributionStats {
+	stats := DistributionStats{Counts: make(map[string]int)}
+	for _, member := range ch.Members() {
+		stats.Counts[member] = 0
+	}
+	servers, err := ch.GetBatch(keys)
+	if err != nil || len(stats.Counts) == 0 {
+		return stats
+	}
+	for _, server := range servers {
+		stats.Counts[server]++
+	}
+	stats.Min = math.MaxInt32
+	var sum, sumSquares float64
+	for _, count := range stats.Counts {
+		sum += float64(count)
+		sumSquares += float64(count) * float64(count)
+		if count < stats.Min {
+			stats.Min = count
+		}
+		if count > stats.Max {
+			stats.Max = count
+		}
+	}
+	n := float64(len(stats.Counts))
+	stats.Mean = sum / n
+	stats.StdDev = math.Sqrt(math.Max(sumSquares/n-stats.Mean*stats.Mean, 0))
+	if stats.Mean > 0 {
+		stats.CV = stats.StdDev / stats.Mean
+	}
+	return stats
+}
+
+// ExpectedShare returns every member's fraction of the vnodes on the ring, its theoretical share of keys
+// Comparing it with the Counts of Stats shows whether weights set with AddWithNodeCount achieve what was intended
+func (ch *ConsistentHash) ExpectedShare() map[string]float64 {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	shares := make(map[string]float64, len(ch.owned))
+	for address, tokens := range ch.owned {
+		shares[address] = float64(len(tokens)) / float64(len(ch.positions))
+	}
+	return shares
+}
+
+// LoadFactors maps keys onto the consistentHash with Get and returns each member's key count divided by the mean,
+// so 1 is a fair share and the largest factor is the max/mean ratio a bounded load capacity has to absorb
+// Every factor is 0 if there are no keys or no members
+func (ch *ConsistentHash) LoadFactors(keys [][]byte) map[string]float64 {
+	stats := ch.Stats(keys)
+	factors := make(map[string]float64, len(stats.Counts))
+	for address, count := range stats.Counts {
+		if stats.Mean > 0 {
+			factors[address] = float64(count) / stats.Mean
+		} else {
+			factors[address] = 0
+		}
+	}
+	return factors
+}
+
+// DistributionGauges maps keys onto the consistentHash and returns the fraction of them each member receives, one
+// value per member ready to be exported as a labelled gauge without depending on a metrics library
+// The fractions add up to 1, every fraction is 0 if there are no keys or they cannot be mapped
+func (ch *ConsistentHash) DistributionGauges(keys [][]byte) map[string]float64 {
+	stats := ch.Stats(keys)
+	total := 0
+	for _, count := range stats.Counts {
+		total += count
+	}
+	gauges := make(map[string]float64, len(stats.Counts))
+	for address, count := range stats.Counts {
+		if total > 0 {
+			gauges[address] = float64(count) / float64(total)
+		} else {
+			gauges[address] = 0
+		}
+	}
+	return gauges
+}
+
+// RecommendVnodeCount estimates the vnode count per server that keeps the coefficient of variation of the servers'
+// shares of the ring at or below targetCV
+// The shares of n servers with v vnodes each follow a Dirichlet distribution whose CV is sqrt((n-1)/(nv+1)), the
+// estimate solves that for v and adds half again so that most rings, not just the average one, meet the target
+// Keys sampled from a finite set add their own noise on top, which more vnodes cannot remove
+// It returns 1 for fewer than 2 servers, which are always balanced, and 0 if targetCV is not positive
+func RecommendVnodeCount(servers int, targetCV float64) int {
+	if !(targetCV > 0) {
+		return 0
+	}
+	if servers < 2 {
+		return 1
+	}
+	n := float64(servers)
+	count := math.Ceil(1.5 * ((n-1)/(targetCV*targetCV) - 1) / n)
+	if count < 1 {
+		return 1
+	}
+	if count > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(count)
+}
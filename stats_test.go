@@ -0,0 +1,132 @@
+package consistentHash
+
+import (
+	"math"
+	"testing"
+
+	"github.com/GaryBoone/GoStats/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	ch := New()
+	serverCount := 10
+	addServers(ch, serverCount)
+	distribution := ch.Stats(keys)
+	assert.Equal(t, serverCount, len(distribution.Counts))
+	sum := 0
+	stat := stats.Stats{}
+	for _, count := range distribution.Counts {
+		sum += count
+		stat.Update(float64(count))
+		assert.True(t, distribution.Min <= count && count <= distribution.Max)
+	}
+	assert.Equal(t, len(keys), sum)
+	assert.InDelta(t, float64(len(keys))/float64(serverCount), distribution.Mean, 1e-9)
+	assert.InDelta(t, stat.PopulationStandardDeviation(), distribution.StdDev, 1e-6)
+	assert.InDelta(t, distribution.StdDev/distribution.Mean, distribution.CV, 1e-9)
+	t.Logf("mean=%.1f stddev=%.2f min=%d max=%d cv=%.3f", distribution.Mean, distribution.StdDev, distribution.Min, distribution.Max, distribution.CV)
+}
+
+func TestStatsEmpty(t *testing.T) {
+	ch := New()
+	distribution := ch.Stats(keys)
+	assert.Empty(t, distribution.Counts)
+	assert.Equal(t, 0.0, distribution.Mean)
+
+	ch.Add("server1")
+	distribution = ch.Stats(nil)
+	assert.Equal(t, map[string]int{"server1": 0}, distribution.Counts)
+	assert.Equal(t, 0.0, distribution.CV)
+}
+
+// TestExpectedShare verifies that shares follow vnode counts and roughly match the observed distribution
+func TestExpectedShare(t *testing.T) {
+	ch := New()
+	assert.Empty(t, ch.ExpectedShare())
+	ch.AddWithNodeCount("single", 200)
+	ch.AddWithNodeCount("double", 400)
+	ch.AddWithNodeCount("other", 400)
+	shares := ch.ExpectedShare()
+	assert.Equal(t, 3, len(shares))
+	assert.InDelta(t, 0.2, shares["single"], 1e-9)
+	assert.InDelta(t, 2*shares["single"], shares["double"], 1e-9)
+	observed := ch.Stats(keys)
+	for server, share := range shares {
+		assert.InDelta(t, share, float64(observed.Counts[server])/float64(len(keys)), 0.05)
+	}
+}
+
+// TestLoadFactors verifies that the factors average to 1 and that the largest matches Max over Mean
+func TestLoadFactors(t *testing.T) {
+	ch := New()
+	addServers(ch, 10)
+	factors := ch.LoadFactors(keys)
+	assert.Equal(t, 10, len(factors))
+	sum := 0.0
+	for _, factor := range factors {
+		sum += factor
+	}
+	assert.InDelta(t, 1.0, sum/float64(len(factors)), 1e-9)
+	distribution := ch.Stats(keys)
+	max := 0.0
+	for _, factor := range factors {
+		max = math.Max(max, factor)
+	}
+	assert.InDelta(t, float64(distribution.Max)/distribution.Mean, max, 1e-9)
+
+	assert.Equal(t, map[string]float64{}, New().LoadFactors(keys))
+	assert.Equal(t, 0.0, ch.LoadFactors(nil)["server0"])
+}
+
+// TestDistributionGauges verifies that the fractions add up to 1 and follow the key counts of Stats
+func TestDistributionGauges(t *testing.T) {
+	ch := New()
+	assert.Equal(t, map[string]float64{}, ch.DistributionGauges(keys))
+	addServers(ch, 10)
+	gauges := ch.DistributionGauges(keys)
+	counts := ch.Stats(keys).Counts
+	assert.Equal(t, 10, len(gauges))
+	sum := 0.0
+	for server, gauge := range gauges {
+		sum += gauge
+		assert.InDelta(t, float64(counts[server])/float64(len(keys)), gauge, 1e-12)
+	}
+	assert.InDelta(t, 1.0, sum, 1e-9)
+	assert.Equal(t, 0.0, ch.DistributionGauges(nil)["server0"])
+}
+
+// segmentCV returns the coefficient of variation of the servers' shares of the ring
+func segmentCV(ch *ConsistentHash) float64 {
+	shares := make(map[string]float64)
+	for _, segment := range ch.Segments() {
+		shares[segment.Server] += segment.Share()
+	}
+	stat := stats.Stats{}
+	for _, member := range ch.Members() {
+		stat.Update(shares[member])
+	}
+	// the shares add up to 1, so their mean is 1/n
+	return stat.PopulationStandardDeviation() * float64(len(ch.Members()))
+}
+
+// TestRecommendVnodeCount verifies that rings built with the recommended count meet the target CV
+func TestRecommendVnodeCount(t *testing.T) {
+	assert.Equal(t, 0, RecommendVnodeCount(10, 0))
+	assert.Equal(t, 1, RecommendVnodeCount(1, 0.01))
+	assert.Equal(t, 1, RecommendVnodeCount(10, 10))
+	assert.True(t, RecommendVnodeCount(10, 0.05) > RecommendVnodeCount(10, 0.1))
+	for _, test := range []struct {
+		servers  int
+		targetCV float64
+	}{
+		{5, 0.05}, {10, 0.1}, {10, 0.05}, {50, 0.2}, {100, 0.1},
+	} {
+		count := RecommendVnodeCount(test.servers, test.targetCV)
+		ch := New(WithVnodeCount(count))
+		addServers(ch, test.servers)
+		cv := segmentCV(ch)
+		t.Logf("servers=%d target=%.2f vnodes=%d cv=%.3f", test.servers, test.targetCV, count, cv)
+		assert.True(t, cv <= test.targetCV, "%d servers with %d vnodes have cv %.3f above %.2f", test.servers, count, cv, test.targetCV)
+	}
+}
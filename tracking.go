@@ -0,0 +1,141 @@
+package consistentHash
+
+import (
+	"math"
+	"sync"
+)
+
+// DefaultLoadFactor is the bound GetTracked places on every member's load, relative to the average load
+const DefaultLoadFactor = 1.25
+
+// loadTracker holds the keys GetTracked has assigned and the resulting load of every member
+// It has its own mutex so lookups can update it while only holding the read lock of the ring
+type loadTracker struct {
+	mutex    sync.Mutex
+	assigned map[string]string
+	loads    map[string]int64
+}
+
+func newLoadTracker() *loadTracker {
+	return &loadTracker{assigned: make(map[string]string), loads: make(map[string]int64)}
+}
+
+// release forgets a key assigned to address
+func (lt *loadTracker) release(key, address string) {
+	delete(lt.assigned, key)
+	if lt.loads[address]--; lt.loads[address] <= 0 {
+		delete(lt.loads, address)
+	}
+}
+
+// retain forgets every key assigned to a server for which keep returns false
+func (lt *loadTracker) retain(keep func(address string) bool) {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	for key, address := range lt.assigned {
+		if !keep(address) {
+			lt.release(key, address)
+		}
+	}
+}
+
+// rename moves the keys assigned to old over to new
+func (lt *loadTracker) rename(old, new string) {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	for key, address := range lt.assigned {
+		if address == old {
+			lt.assigned[key] = new
+		}
+	}
+	if load, found := lt.loads[old]; found {
+		lt.loads[new] = load
+		delete(lt.loads, old)
+	}
+}
+
+// WithLoadTracking makes the consistentHash remember the keys placed with GetTracked and the load they put on
+// every member, so it can balance them with bounded loads without the caller keeping a load map
+func WithLoadTracking() Option {
+	return func(ch *ConsistentHash) {
+		ch.tracker = newLoadTracker()
+	}
+}
+
+// GetTracked assigns a key to the closest member whose load stays within DefaultLoadFactor times the average load
+// once the key is added, like GetBounded, and counts the key against that member until it is released
+// A key that is already assigned returns its member again without being counted twice
+// Draining servers are skipped, and keys of a server that leaves the ring are released with it
+// ErrLoadTrackingDisabled is returned unless the consistentHash was created with WithLoadTracking
+func (ch *ConsistentHash) GetTracked(key []byte) (string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if ch.tracker == nil {
+		return "", ErrLoadTrackingDisabled
+	}
+	lt := ch.tracker
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	if address, found := lt.assigned[string(key)]; found {
+		return address, nil
+	}
+	capacity := int64(math.Ceil(DefaultLoadFactor * float64(len(lt.assigned)+1) / float64(len(ch.nodeCount))))
+	found, err := ch.bounded(key, func(address string) bool {
+		return ch.draining[address] || lt.loads[address] >= capacity
+	})
+	if err != nil {
+		return "", err
+	}
+	lt.assigned[string(key)] = found
+	lt.loads[found]++
+	return found, nil
+}
+
+// Release stops counting a key assigned by GetTracked against its member
+// ErrLoadTrackingDisabled is returned unless load tracking is enabled and ErrKeyNotTracked if the key is not assigned
+func (ch *ConsistentHash) Release(key []byte) error {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if ch.tracker == nil {
+		return ErrLoadTrackingDisabled
+	}
+	lt := ch.tracker
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	address, found := lt.assigned[string(key)]
+	if !found {
+		return ErrKeyNotTracked
+	}
+	lt.release(string(key), address)
+	return nil
+}
+
+// ReleaseServer releases every key GetTracked assigned to a server, bringing its load back to 0
+// ErrLoadTrackingDisabled is returned unless load tracking is enabled
+func (ch *ConsistentHash) ReleaseServer(address string) error {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if ch.tracker == nil {
+		return ErrLoadTrackingDisabled
+	}
+	ch.tracker.retain(func(assigned string) bool { return assigned != address })
+	return nil
+}
+
+// TrackedLoads returns the number of keys assigned to every member by GetTracked, members without keys are left out
+// It returns nil unless load tracking is enabled
+func (ch *ConsistentHash) TrackedLoads() map[string]int64 {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if ch.tracker == nil {
+		return nil
+	}
+	lt := ch.tracker
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	loads := make(map[string]int64, len(lt.loads))
+	for address, load := range lt.loads {
+		loads[address] = load
+	}
+	return loads
+}
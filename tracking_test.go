@@ -0,0 +1,67 @@
+package consistentHash
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetTrackedBound verifies that no member ever goes above the bound computed from the keys assigned so far
+func TestGetTrackedBound(t *testing.T) {
+	ch := New(WithLoadTracking())
+	serverCount := 10
+	addServers(ch, serverCount)
+	for i, key := range keys {
+		server, err := ch.GetTracked(key)
+		assert.Nil(t, err)
+		capacity := int64(math.Ceil(DefaultLoadFactor * float64(i+1) / float64(serverCount)))
+		if load := ch.TrackedLoads()[server]; load > capacity {
+			t.Fatalf("%s has load %d above capacity %d after %d keys", server, load, capacity, i+1)
+		}
+	}
+	total := int64(0)
+	for _, load := range ch.TrackedLoads() {
+		total += load
+	}
+	assert.Equal(t, int64(len(keys)), total)
+}
+
+// TestGetTrackedRelease verifies that assignments are counted once and released by key, by server and on removal
+func TestGetTrackedRelease(t *testing.T) {
+	_, err := New().GetTracked(keys[0])
+	assert.Equal(t, ErrLoadTrackingDisabled, err)
+	assert.Equal(t, ErrLoadTrackingDisabled, New().Release(keys[0]))
+	assert.Equal(t, ErrLoadTrackingDisabled, New().ReleaseServer("server0"))
+	assert.Nil(t, New().TrackedLoads())
+
+	ch := New(WithLoadTracking())
+	_, err = ch.GetTracked(keys[0])
+	assert.Equal(t, ErrEmptyRing, err)
+	addServers(ch, 3)
+	server, err := ch.GetTracked(keys[0])
+	assert.Nil(t, err)
+	again, _ := ch.GetTracked(keys[0])
+	assert.Equal(t, server, again)
+	assert.Equal(t, map[string]int64{server: 1}, ch.TrackedLoads())
+	assert.Nil(t, ch.Release(keys[0]))
+	assert.Equal(t, map[string]int64{}, ch.TrackedLoads())
+	assert.Equal(t, ErrKeyNotTracked, ch.Release(keys[0]))
+
+	for _, key := range keys[:300] {
+		ch.GetTracked(key)
+	}
+	clone := ch.Clone()
+	assert.Nil(t, ch.ReleaseServer("server0"))
+	assert.Equal(t, int64(0), ch.TrackedLoads()["server0"])
+	assert.NotEqual(t, int64(0), clone.TrackedLoads()["server0"])
+
+	assert.Nil(t, ch.Remove("server1"))
+	assert.Equal(t, int64(0), ch.TrackedLoads()["server1"])
+	for _, key := range keys[:300] {
+		if server, _ := ch.GetTracked(key); server != "server2" {
+			assert.Nil(t, ch.Release(key))
+		}
+	}
+	assert.Equal(t, 1, len(ch.TrackedLoads()))
+}
@@ -0,0 +1,52 @@
+package consistentHash
+
+import "fmt"
+
+// Validate checks the internal invariants of the ring, returning an error wrapping ErrInvalidRing for the first one
+// that is broken: the vnodes must be sorted, every vnode must be owned by a member, every member must own vnodes and
+// the per-server bookkeeping must match the vnodes on the ring
+// It is meant for tests and sanity checks after decoding, a ring only changed through its methods is always valid
+func (ch *ConsistentHash) Validate() error {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if len(ch.positions) != len(ch.owners) {
+		return fmt.Errorf("%w: %d positions but %d owners", ErrInvalidRing, len(ch.positions), len(ch.owners))
+	}
+	if len(ch.replicas) != len(ch.positions) {
+		return fmt.Errorf("%w: %d positions but %d replica indexes", ErrInvalidRing, len(ch.positions), len(ch.replicas))
+	}
+	if ch.lows != nil && len(ch.lows) != len(ch.positions) {
+		return fmt.Errorf("%w: %d positions but %d low bits", ErrInvalidRing, len(ch.positions), len(ch.lows))
+	}
+	counts := make(map[string]int, len(ch.nodeCount))
+	for i := range ch.positions {
+		if int(ch.owners[i]) >= len(ch.names) {
+			return fmt.Errorf("%w: vnode %d has unknown owner index %d", ErrInvalidRing, i, ch.owners[i])
+		}
+		owner := ch.owner(i)
+		if _, found := ch.nodeCount[owner]; !found {
+			return fmt.Errorf("%w: vnode %d is owned by %q which is not a member", ErrInvalidRing, i, owner)
+		}
+		if id, found := ch.ids[owner]; !found || id != ch.owners[i] {
+			return fmt.Errorf("%w: %q is not interned at index %d", ErrInvalidRing, owner, ch.owners[i])
+		}
+		if i > 0 && ch.less(i, i-1) {
+			return fmt.Errorf("%w: vnode %d at %d is out of order", ErrInvalidRing, i, ch.positions[i])
+		}
+		counts[owner]++
+	}
+	for address := range ch.nodeCount {
+		if counts[address] == 0 {
+			return fmt.Errorf("%w: member %q has no vnodes", ErrInvalidRing, address)
+		}
+	}
+	if len(ch.owned) != len(counts) {
+		return fmt.Errorf("%w: %d servers tracked but %d on the ring", ErrInvalidRing, len(ch.owned), len(counts))
+	}
+	for address, count := range counts {
+		if len(ch.owned[address]) != count {
+			return fmt.Errorf("%w: %q tracks %d vnodes but owns %d", ErrInvalidRing, address, len(ch.owned[address]), count)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package consistentHash
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidate verifies that rings changed through their methods are valid
+func TestValidate(t *testing.T) {
+	assert.Nil(t, New().Validate())
+	ch := New(WithHashFunc(collidingHash), WithVnodeCount(20))
+	addServers(ch, 5)
+	assert.Nil(t, ch.Validate())
+	ch.Remove("server2")
+	ch.Rename("server3", "renamed")
+	ch.SetNodeVnodeCount("server0", 5)
+	assert.Nil(t, ch.Validate())
+	assert.Nil(t, New(WithKetamaCompat()).Validate())
+}
+
+// TestValidateCorrupt verifies that each kind of corruption is reported as ErrInvalidRing
+func TestValidateCorrupt(t *testing.T) {
+	corruptions := map[string]func(ch *ConsistentHash){
+		"unsorted": func(ch *ConsistentHash) {
+			ch.positions[0], ch.positions[1] = ch.positions[1], ch.positions[0]
+		},
+		"dangling owner": func(ch *ConsistentHash) {
+			delete(ch.nodeCount, "server1")
+		},
+		"missing owner": func(ch *ConsistentHash) {
+			ch.owners[0] = uint32(len(ch.names))
+		},
+		"member without vnodes": func(ch *ConsistentHash) {
+			ch.nodeCount["ghost"] = 1
+		},
+		"count mismatch": func(ch *ConsistentHash) {
+			ch.owned["server0"] = ch.owned["server0"][1:]
+		},
+		"length mismatch": func(ch *ConsistentHash) {
+			ch.owners = ch.owners[1:]
+		},
+	}
+	for name, corrupt := range corruptions {
+		ch := New(WithVnodeCount(10))
+		addServers(ch, 3)
+		corrupt(ch)
+		err := ch.Validate()
+		assert.True(t, errors.Is(err, ErrInvalidRing), name)
+	}
+}
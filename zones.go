@@ -0,0 +1,58 @@
+package consistentHash
+
+// AddWithZone adds a server to the consistentHash with the configured vnode count and records its zone
+// (rack, availability zone or any other failure domain) for GetNDistinctZones
+// Members added without a zone are treated as sharing the empty zone ""
+func (ch *ConsistentHash) AddWithZone(address string, zone string) error {
+	return ch.update(func() ([]string, []string, error) {
+		if err := ch.add(address, ch.vnodeCount); err != nil {
+			return nil, nil, err
+		}
+		ch.zones[address] = zone
+		return []string{address}, nil, nil
+	})
+}
+
+// Zone returns the zone a member was added with, or "" if it has none
+func (ch *ConsistentHash) Zone(address string) string {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	return ch.zones[address]
+}
+
+// GetNDistinctZones finds the closest N members for a given key such that no two share a zone
+// The walk goes once around the ring and takes the first member seen from each zone, so a zone holding most of the
+// members cannot crowd out the others
+// ErrNotEnoughZones is returned if the members span fewer than N zones
+func (ch *ConsistentHash) GetNDistinctZones(key []byte, count int) ([]string, error) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+	if err := ch.routable(); err != nil {
+		return nil, err
+	}
+	zoneCount := make(map[string]bool)
+	for address := range ch.nodeCount {
+		zoneCount[ch.zones[address]] = true
+	}
+	if len(zoneCount) < count {
+		return nil, ErrNotEnoughZones
+	}
+	if count < 1 {
+		return []string{}, nil
+	}
+	index, err := ch.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	zoneMap := make(map[string]bool)
+	addresses := make([]string, 0, count)
+	ch.successors(index, func(address string) bool {
+		zone := ch.zones[address]
+		if !zoneMap[zone] {
+			zoneMap[zone] = true
+			addresses = append(addresses, address)
+		}
+		return len(addresses) < count
+	})
+	return addresses, nil
+}
@@ -0,0 +1,39 @@
+package consistentHash
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetNDistinctZones verifies that every replica set spans 3 zones even when one zone holds most servers
+func TestGetNDistinctZones(t *testing.T) {
+	ch := New()
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, ch.AddWithZone("a"+strconv.Itoa(i), "zoneA"))
+	}
+	ch.AddWithZone("b0", "zoneB")
+	ch.AddWithZone("b1", "zoneB")
+	ch.AddWithZone("c0", "zoneC")
+	assert.Equal(t, ErrNodeExists, ch.AddWithZone("c0", "zoneB"))
+	assert.Equal(t, "zoneC", ch.Zone("c0"))
+
+	for _, key := range keys {
+		servers, err := ch.GetNDistinctZones(key, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(servers))
+		zones := make(map[string]bool)
+		for _, server := range servers {
+			zones[ch.Zone(server)] = true
+		}
+		assert.Equal(t, 3, len(zones))
+	}
+	_, err := ch.GetNDistinctZones(keys[0], 4)
+	assert.Equal(t, ErrNotEnoughZones, err)
+
+	ch.Remove("c0")
+	assert.Equal(t, "", ch.Zone("c0"))
+	_, err = ch.GetNDistinctZones(keys[0], 3)
+	assert.Equal(t, ErrNotEnoughZones, err)
+}